@@ -0,0 +1,44 @@
+// Command checkledger checks the ledger of a user, or of every user, for
+// wallets whose balance drifted off their postings and for transactions which
+// lack postings, and optionally repairs them. The report is printed as JSON.
+//
+//	checkledger [-user <id>] [-repair]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/expenseledger/web-service/db"
+	"github.com/expenseledger/web-service/model"
+	"github.com/shopspring/decimal"
+)
+
+func main() {
+	userId := flag.String("user", "", "check only the ledger of this user")
+	repair := flag.Bool("repair", false, "repair the issues found")
+	flag.Parse()
+
+	decimal.MarshalJSONWithoutQuotes = true
+
+	if err := db.CreateTables(); err != nil {
+		log.Fatal("Error creating tables")
+	}
+
+	report, err := model.CheckLedger(*repair, *userId)
+	if err != nil {
+		log.Fatal("Error checking the ledger ", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal("Error printing the report ", err)
+	}
+
+	if len(report.Issues) > 0 && !*repair {
+		os.Exit(1)
+	}
+}
@@ -3,13 +3,20 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type configFields struct {
-	Mode string
-	Port string
+	Mode              string
+	Port              string
+	SchedulerInterval time.Duration
+	DefaultCurrency   string
+	AttachmentDir     string
+	AttachmentMaxSize int64
+	TrashRetention    time.Duration
 }
 
 var configs configFields
@@ -26,6 +33,39 @@ func init() {
 	if configs.Port == "" {
 		configs.Port = "3000"
 	}
+
+	configs.DefaultCurrency = os.Getenv("DEFAULT_CURRENCY")
+	if configs.DefaultCurrency == "" {
+		configs.DefaultCurrency = "THB"
+	}
+
+	configs.AttachmentDir = os.Getenv("ATTACHMENT_DIR")
+	if configs.AttachmentDir == "" {
+		configs.AttachmentDir = "attachments"
+	}
+
+	configs.AttachmentMaxSize, err = strconv.ParseInt(
+		os.Getenv("ATTACHMENT_MAX_SIZE"),
+		10,
+		64,
+	)
+	if err != nil || configs.AttachmentMaxSize <= 0 {
+		configs.AttachmentMaxSize = 10 << 20
+	}
+
+	configs.TrashRetention, err = time.ParseDuration(
+		os.Getenv("TRASH_RETENTION"),
+	)
+	if err != nil || configs.TrashRetention <= 0 {
+		configs.TrashRetention = 30 * 24 * time.Hour
+	}
+
+	configs.SchedulerInterval, err = time.ParseDuration(
+		os.Getenv("SCHEDULER_INTERVAL"),
+	)
+	if err != nil || configs.SchedulerInterval <= 0 {
+		configs.SchedulerInterval = 10 * time.Minute
+	}
 }
 
 // GetConfigs ...
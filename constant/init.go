@@ -8,6 +8,13 @@ import (
 type TransactionType string
 type WalletRole string
 type WalletType string
+type Frequency string
+type TransactionStatus string
+type LedgerIssueKind string
+type CategoryKind string
+type EnvelopeEntryKind string
+type OverdraftPolicy string
+type ErrorCode string
 
 type transactionType struct {
 	once     sync.Once
@@ -29,7 +36,65 @@ type walletType struct {
 	Credit      WalletType
 }
 
+type frequency struct {
+	once    sync.Once
+	Daily   Frequency
+	Weekly  Frequency
+	Monthly Frequency
+	Yearly  Frequency
+}
+
+type transactionStatus struct {
+	once       sync.Once
+	Pending    TransactionStatus
+	Cleared    TransactionStatus
+	Reconciled TransactionStatus
+}
+
+type ledgerIssueKind struct {
+	once           sync.Once
+	Drift          LedgerIssueKind
+	Orphan         LedgerIssueKind
+	HalfTransfer   LedgerIssueKind
+	Malformed      LedgerIssueKind
+	OpeningBalance LedgerIssueKind
+}
+
+type categoryKind struct {
+	once     sync.Once
+	Expense  CategoryKind
+	Income   CategoryKind
+	Transfer CategoryKind
+	Any      CategoryKind
+}
+
+type envelopeEntryKind struct {
+	once   sync.Once
+	Assign EnvelopeEntryKind
+	Move   EnvelopeEntryKind
+}
+
+type overdraftPolicy struct {
+	once     sync.Once
+	Disallow OverdraftPolicy
+	Allow    OverdraftPolicy
+	Floor    OverdraftPolicy
+}
+
+type errorCode struct {
+	once              sync.Once
+	InsufficientFunds ErrorCode
+	OverCreditLimit   ErrorCode
+}
+
 var (
+	ec errorCode
+	op overdraftPolicy
+	ek envelopeEntryKind
+	ck categoryKind
+	lk ledgerIssueKind
+	ts transactionStatus
+	fq frequency
 	wt walletType
 	tt transactionType
 	wr walletRole
@@ -64,6 +129,84 @@ func WalletRoles() walletRole {
 	return wr
 }
 
+// Frequencies returns how often a recurring transaction can repeat
+func Frequencies() frequency {
+	fq.once.Do(func() {
+		fq.Daily = "DAILY"
+		fq.Weekly = "WEEKLY"
+		fq.Monthly = "MONTHLY"
+		fq.Yearly = "YEARLY"
+	})
+	return fq
+}
+
+// TransactionStatuses returns whether a transaction has hit the bank
+func TransactionStatuses() transactionStatus {
+	ts.once.Do(func() {
+		ts.Pending = "PENDING"
+		ts.Cleared = "CLEARED"
+		ts.Reconciled = "RECONCILED"
+	})
+	return ts
+}
+
+// LedgerIssueKinds returns the ways the ledger of a user can be inconsistent,
+// OpeningBalance marks an opening balance backfilled from a stored balance
+// which could have drifted already
+func LedgerIssueKinds() ledgerIssueKind {
+	lk.once.Do(func() {
+		lk.Drift = "DRIFT"
+		lk.Orphan = "ORPHAN"
+		lk.HalfTransfer = "HALF_TRANSFER"
+		lk.Malformed = "MALFORMED"
+		lk.OpeningBalance = "OPENING_BALANCE"
+	})
+	return lk
+}
+
+// CategoryKinds returns the types of transactions a category is for, each
+// kind but Any is named after the transaction type it is for
+func CategoryKinds() categoryKind {
+	ck.once.Do(func() {
+		ck.Expense = "EXPENSE"
+		ck.Income = "INCOME"
+		ck.Transfer = "TRANSFER"
+		ck.Any = "ANY"
+	})
+	return ck
+}
+
+// EnvelopeEntryKinds returns the ways money gets into or out of an envelope
+// other than by spending it
+func EnvelopeEntryKinds() envelopeEntryKind {
+	ek.once.Do(func() {
+		ek.Assign = "ASSIGN"
+		ek.Move = "MOVE"
+	})
+	return ek
+}
+
+// OverdraftPolicies returns how far spending may take the balance of a
+// wallet, not below zero, as far as it goes or down to a floor of its own
+func OverdraftPolicies() overdraftPolicy {
+	op.once.Do(func() {
+		op.Disallow = "DISALLOW"
+		op.Allow = "ALLOW"
+		op.Floor = "FLOOR"
+	})
+	return op
+}
+
+// ErrorCodes returns the codes of the errors the app tells apart without
+// reading their messages
+func ErrorCodes() errorCode {
+	ec.once.Do(func() {
+		ec.InsufficientFunds = "INSUFFICIENT_FUNDS"
+		ec.OverCreditLimit = "OVER_CREDIT_LIMIT"
+	})
+	return ec
+}
+
 // ListWalletTypes returns the types of wallets as a slice of strings
 func ListWalletTypes() []string {
 	wt := WalletTypes()
@@ -89,3 +232,56 @@ func ListTransactionTypes() []string {
 
 	return types
 }
+
+// ListFrequencies returns the frequencies as a slice of strings
+func ListFrequencies() []string {
+	f := Frequencies()
+	v := reflect.ValueOf(f)
+	frequencies := make([]string, v.NumField()-1)
+
+	for i := 1; i < v.NumField(); i++ {
+		frequencies[i-1] = v.Field(i).String()
+	}
+
+	return frequencies
+}
+
+// ListTransactionStatuses returns the statuses of transactions as a slice of
+// strings
+func ListTransactionStatuses() []string {
+	t := TransactionStatuses()
+	v := reflect.ValueOf(t)
+	statuses := make([]string, v.NumField()-1)
+
+	for i := 1; i < v.NumField(); i++ {
+		statuses[i-1] = v.Field(i).String()
+	}
+
+	return statuses
+}
+
+// ListCategoryKinds returns the kinds of categories as a slice of strings
+func ListCategoryKinds() []string {
+	k := CategoryKinds()
+	v := reflect.ValueOf(k)
+	kinds := make([]string, v.NumField()-1)
+
+	for i := 1; i < v.NumField(); i++ {
+		kinds[i-1] = v.Field(i).String()
+	}
+
+	return kinds
+}
+
+// ListOverdraftPolicies returns the overdraft policies as a slice of strings
+func ListOverdraftPolicies() []string {
+	p := OverdraftPolicies()
+	v := reflect.ValueOf(p)
+	policies := make([]string, v.NumField()-1)
+
+	for i := 1; i < v.NumField(); i++ {
+		policies[i-1] = v.Field(i).String()
+	}
+
+	return policies
+}
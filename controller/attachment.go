@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/expenseledger/web-service/config"
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+type attachmentIdentifyForm struct {
+	ID string `json:"id" binding:"required"`
+}
+
+type attachmentListForm struct {
+	TransactionID string `json:"transaction_id" binding:"required"`
+}
+
+// uploadAttachment takes the file field of a multipart form and attaches it
+// to the transaction in its transaction_id field
+func uploadAttachment(context *gin.Context) {
+	// the form may hold a little more than the file itself
+	maxSize := config.GetConfigs().AttachmentMaxSize + 1<<20
+	context.Request.Body = http.MaxBytesReader(
+		context.Writer,
+		context.Request.Body,
+		maxSize,
+	)
+
+	transactionID := context.PostForm("transaction_id")
+	header, err := context.FormFile("file")
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+	defer file.Close()
+
+	attachment, err := model.AddAttachment(
+		transactionID,
+		header.Filename,
+		file,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, attachment)
+}
+
+// downloadAttachment responds with the content of the attachment rather than
+// with JSON
+func downloadAttachment(context *gin.Context) {
+	var form attachmentIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	attachment, content, err := model.OpenAttachment(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+	defer content.Close()
+
+	headers := map[string]string{
+		"Content-Disposition": mime.FormatMediaType(
+			"attachment",
+			map[string]string{"filename": attachment.FileName},
+		),
+	}
+
+	context.DataFromReader(
+		http.StatusOK,
+		attachment.Size,
+		attachment.ContentType,
+		content,
+		headers,
+	)
+}
+
+func getAttachment(context *gin.Context) {
+	var form attachmentIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	attachment, err := model.GetAttachment(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, attachment)
+}
+
+func deleteAttachment(context *gin.Context) {
+	var form attachmentIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	attachment, err := model.DeleteAttachment(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, attachment)
+}
+
+func listAttachments(context *gin.Context) {
+	var form attachmentListForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	attachments, err := model.ListAttachments(form.TransactionID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(attachments),
+		Items:  attachments,
+	}
+
+	buildSuccessContext(context, items)
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type budgetIdentifyForm struct {
+	ID string `json:"id" binding:"required"`
+}
+
+type budgetCreateForm struct {
+	Name       string             `json:"name"`
+	Categories []string           `json:"categories" binding:"required"`
+	Period     constant.Frequency `json:"period"`
+	Amount     decimal.Decimal    `json:"amount" binding:"required"`
+	Currency   string             `json:"currency"`
+}
+
+type budgetUpdateForm struct {
+	ID string `json:"id" binding:"required"`
+	budgetCreateForm
+}
+
+type budgetProgressForm struct {
+	// ID, when given, is the only budget whose progress is returned
+	ID string `json:"id"`
+	// Date is a day in the latest period returned, today when not given
+	Date    date.Date `json:"date"`
+	Periods int       `json:"periods" binding:"min=0"`
+}
+
+func (form *budgetCreateForm) toBudget() model.Budget {
+	return model.Budget{
+		Name:       form.Name,
+		Categories: form.Categories,
+		Period:     form.Period,
+		Amount:     form.Amount,
+		Currency:   form.Currency,
+	}
+}
+
+func createBudget(context *gin.Context) {
+	var form budgetCreateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	budget, err := model.CreateBudget(form.toBudget(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, budget)
+}
+
+func getBudget(context *gin.Context) {
+	var form budgetIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	budget, err := model.GetBudget(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, budget)
+}
+
+func updateBudget(context *gin.Context) {
+	var form budgetUpdateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	budget := form.toBudget()
+	budget.ID = form.ID
+
+	updated, err := model.UpdateBudget(budget, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, updated)
+}
+
+func deleteBudget(context *gin.Context) {
+	var form budgetIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	budget, err := model.DeleteBudget(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, budget)
+}
+
+func listBudgets(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	budgets, info, err := model.ListBudgets(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(budgets),
+		Items:      budgets,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func getBudgetProgress(context *gin.Context) {
+	var form budgetProgressForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	progress, err := model.GetBudgetProgress(
+		form.ID,
+		form.Date,
+		form.Periods,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(progress),
+		Items:  progress,
+	}
+
+	buildSuccessContext(context, items)
+}
@@ -1,8 +1,10 @@
 package controller
 
 import (
+	"github.com/expenseledger/web-service/constant"
 	"github.com/expenseledger/web-service/model"
 	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,8 +12,45 @@ type categoryIDForm struct {
 	Name string `json:"name" binding:"required"`
 }
 
+type categoryCreateForm struct {
+	Name string                `json:"name" binding:"required"`
+	Kind constant.CategoryKind `json:"kind"`
+	// Parent, when given, is the category the new one is filed under
+	Parent string `json:"parent"`
+}
+
+type categoryKindForm struct {
+	Name string                `json:"name" binding:"required"`
+	Kind constant.CategoryKind `json:"kind" binding:"required"`
+}
+
+type categoryParentForm struct {
+	Name string `json:"name" binding:"required"`
+	// Parent is the category to file the category under, an empty one puts
+	// it at the top
+	Parent string `json:"parent"`
+}
+
+type categoryTotalForm struct {
+	Categories []string  `json:"categories"`
+	FromDate   date.Date `json:"from_date"`
+	ToDate     date.Date `json:"to_date"`
+}
+
+type categoryRenameForm struct {
+	Name    string `json:"name" binding:"required"`
+	NewName string `json:"new_name" binding:"required"`
+}
+
+type categoryMergeForm struct {
+	Name string `json:"name" binding:"required"`
+	// Into is the existing category which takes over what is filed under
+	// Name
+	Into string `json:"into" binding:"required"`
+}
+
 func createCategory(context *gin.Context) {
-	var form categoryIDForm
+	var form categoryCreateForm
 	if err := bindJSON(context, &form); err != nil {
 		return
 	}
@@ -22,7 +61,12 @@ func createCategory(context *gin.Context) {
 		return
 	}
 
-	category, err := model.CreateCategory(form.Name, userId)
+	category, err := model.CreateCategory(
+		form.Name,
+		form.Kind,
+		form.Parent,
+		userId,
+	)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
@@ -52,6 +96,100 @@ func getCategory(context *gin.Context) {
 	buildSuccessContext(context, category)
 }
 
+func setCategoryKind(context *gin.Context) {
+	var form categoryKindForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	category, err := model.SetCategoryKind(form.Name, form.Kind, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, category)
+}
+
+func listCategoryKinds(context *gin.Context) {
+	kinds := constant.ListCategoryKinds()
+	items := itemList{
+		Length: len(kinds),
+		Items:  kinds,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func setCategoryParent(context *gin.Context) {
+	var form categoryParentForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	category, err := model.SetCategoryParent(form.Name, form.Parent, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, category)
+}
+
+func renameCategory(context *gin.Context) {
+	var form categoryRenameForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	move, err := model.RenameCategory(form.Name, form.NewName, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, move)
+}
+
+func mergeCategory(context *gin.Context) {
+	var form categoryMergeForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	move, err := model.MergeCategory(form.Name, form.Into, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, move)
+}
+
 func deleteCategory(context *gin.Context) {
 	var form categoryIDForm
 	if err := bindJSON(context, &form); err != nil {
@@ -74,22 +212,109 @@ func deleteCategory(context *gin.Context) {
 	buildSuccessContext(context, category)
 }
 
+func restoreCategory(context *gin.Context) {
+	var form categoryIDForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	category, err := model.RestoreCategory(form.Name, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, category)
+}
+
+func listTrashedCategories(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	categories, info, err := model.ListTrashedCategories(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(categories),
+		Items:      categories,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
 func listCategories(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
 	userId, err := pkg.GetUserId(context)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
 	}
 
-	categories, err := model.ListCategories(userId)
+	categories, info, err := model.ListCategories(form.toPage(), userId)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
 	}
 
 	items := itemList{
-		Length: len(categories),
-		Items:  categories,
+		Length:     len(categories),
+		Items:      categories,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func totalCategories(context *gin.Context) {
+	var form categoryTotalForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	totals, err := model.TotalCategories(
+		form.Categories,
+		form.FromDate,
+		form.ToDate,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(totals),
+		Items:  totals,
 	}
 
 	buildSuccessContext(context, items)
@@ -102,18 +327,25 @@ func initCategories(context *gin.Context) {
 		return
 	}
 
-	names := []string{
-		"Food And Drink",
-		"Transportation",
-		"Shopping",
-		"Bill",
-		"Withdraw",
+	kinds := constant.CategoryKinds()
+	recipes := []categoryCreateForm{
+		categoryCreateForm{Name: "Food And Drink", Kind: kinds.Expense},
+		categoryCreateForm{Name: "Transportation", Kind: kinds.Expense},
+		categoryCreateForm{Name: "Shopping", Kind: kinds.Expense},
+		categoryCreateForm{Name: "Bill", Kind: kinds.Expense},
+		categoryCreateForm{Name: "Salary", Kind: kinds.Income},
+		categoryCreateForm{Name: "Withdraw", Kind: kinds.Transfer},
 	}
 
-	length := len(names)
+	length := len(recipes)
 	categories := make([]*model.Category, length)
-	for i, name := range names {
-		category, err := model.CreateCategory(name, userId)
+	for i, recipe := range recipes {
+		category, err := model.CreateCategory(
+			recipe.Name,
+			recipe.Kind,
+			recipe.Parent,
+			userId,
+		)
 		if err != nil {
 			buildFailedContext(context, err)
 			return
@@ -1,15 +1,29 @@
 package controller
 
 import (
+	"errors"
+	"io"
 	"net/http"
 
+	"github.com/expenseledger/web-service/model"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 type itemList struct {
-	Length int         `json:"length"`
-	Items  interface{} `json:"items"`
+	Length     int         `json:"length"`
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	HasMore    bool        `json:"has_more"`
+}
+
+type pageForm struct {
+	Limit  int    `json:"limit" binding:"min=0"`
+	Cursor string `json:"cursor"`
+}
+
+func (form *pageForm) toPage() model.Page {
+	return model.Page{Limit: form.Limit, Cursor: form.Cursor}
 }
 
 func buildSuccessResponse(data interface{}) map[string]interface{} {
@@ -19,15 +33,24 @@ func buildSuccessResponse(data interface{}) map[string]interface{} {
 	}
 }
 
+// buildNonsuccessResponse also carries the code of a coded error, so the app
+// can tell it apart
 func buildNonsuccessResponse(
 	err error,
 	data interface{},
 ) map[string]interface{} {
-	return gin.H{
+	response := gin.H{
 		"success": false,
 		"message": err.Error(),
 		"context": data,
 	}
+
+	var coded *model.CodedError
+	if errors.As(err, &coded) {
+		response["code"] = coded.Code
+	}
+
+	return response
 }
 
 func bindJSON(context *gin.Context, form interface{}) (err error) {
@@ -41,6 +64,22 @@ func bindJSON(context *gin.Context, form interface{}) (err error) {
 	return
 }
 
+// bindOptionalJSON is bindJSON which also accepts an empty body
+func bindOptionalJSON(context *gin.Context, form interface{}) (err error) {
+	err = context.ShouldBindJSON(form)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		context.JSON(
+			http.StatusBadRequest,
+			buildNonsuccessResponse(err, nil),
+		)
+		return
+	}
+	return
+}
+
 func buildFailedContext(context *gin.Context, err error) {
 	context.JSON(
 		http.StatusBadRequest,
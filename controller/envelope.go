@@ -0,0 +1,254 @@
+package controller
+
+import (
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type envelopeIdentifyForm struct {
+	ID string `json:"id" binding:"required"`
+}
+
+type envelopeCreateForm struct {
+	Name       string    `json:"name" binding:"required"`
+	Categories []string  `json:"categories" binding:"required"`
+	Currency   string    `json:"currency"`
+	StartMonth date.Date `json:"start_month"`
+}
+
+type envelopeUpdateForm struct {
+	ID         string   `json:"id" binding:"required"`
+	Name       string   `json:"name" binding:"required"`
+	Categories []string `json:"categories" binding:"required"`
+}
+
+type envelopeAssignForm struct {
+	ID string `json:"id" binding:"required"`
+	// Month is a day in the month assigned to, the current month when not
+	// given
+	Month  date.Date       `json:"month"`
+	Amount decimal.Decimal `json:"amount" binding:"required"`
+	Note   string          `json:"note"`
+}
+
+type envelopeMoveForm struct {
+	From   string          `json:"from" binding:"required"`
+	To     string          `json:"to" binding:"required"`
+	Month  date.Date       `json:"month"`
+	Amount decimal.Decimal `json:"amount" binding:"required"`
+	Note   string          `json:"note"`
+}
+
+type envelopeLedgerForm struct {
+	FromMonth date.Date `json:"from_month"`
+	ToMonth   date.Date `json:"to_month"`
+}
+
+func createEnvelope(context *gin.Context) {
+	var form envelopeCreateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	envelope := model.Envelope{
+		Name:       form.Name,
+		Categories: form.Categories,
+		Currency:   form.Currency,
+		StartMonth: form.StartMonth,
+	}
+
+	created, err := model.CreateEnvelope(envelope, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, created)
+}
+
+func getEnvelope(context *gin.Context) {
+	var form envelopeIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	envelope, err := model.GetEnvelope(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, envelope)
+}
+
+func updateEnvelope(context *gin.Context) {
+	var form envelopeUpdateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	envelope := model.Envelope{
+		ID:         form.ID,
+		Name:       form.Name,
+		Categories: form.Categories,
+	}
+
+	updated, err := model.UpdateEnvelope(envelope, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, updated)
+}
+
+func deleteEnvelope(context *gin.Context) {
+	var form envelopeIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	envelope, err := model.DeleteEnvelope(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, envelope)
+}
+
+func listEnvelopes(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	envelopes, info, err := model.ListEnvelopes(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(envelopes),
+		Items:      envelopes,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func assignToEnvelope(context *gin.Context) {
+	var form envelopeAssignForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	entry, err := model.AssignToEnvelope(
+		form.ID,
+		form.Month,
+		form.Amount,
+		form.Note,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, entry)
+}
+
+func moveBetweenEnvelopes(context *gin.Context) {
+	var form envelopeMoveForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	entries, err := model.MoveBetweenEnvelopes(
+		form.From,
+		form.To,
+		form.Month,
+		form.Amount,
+		form.Note,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(entries),
+		Items:  entries,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func getEnvelopeLedger(context *gin.Context) {
+	var form envelopeLedgerForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	ledger, err := model.GetEnvelopeLedger(form.FromMonth, form.ToMonth, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, ledger)
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type exchangeRateIdentifyForm struct {
+	From string    `json:"from" binding:"required"`
+	To   string    `json:"to" binding:"required"`
+	Date date.Date `json:"date" binding:"required"`
+}
+
+type exchangeRateSetForm struct {
+	From string          `json:"from" binding:"required"`
+	To   string          `json:"to" binding:"required"`
+	Date date.Date       `json:"date"`
+	Rate decimal.Decimal `json:"rate" binding:"required"`
+}
+
+type exchangeRateListForm struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	pageForm
+}
+
+type exchangeRateConvertForm struct {
+	Amount decimal.Decimal `json:"amount" binding:"required"`
+	From   string          `json:"from" binding:"required"`
+	To     string          `json:"to"`
+	Date   date.Date       `json:"date"`
+}
+
+func setExchangeRate(context *gin.Context) {
+	var form exchangeRateSetForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rate, err := model.SetExchangeRate(
+		model.ExchangeRate{
+			From: form.From,
+			To:   form.To,
+			Date: form.Date,
+			Rate: form.Rate,
+		},
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, rate)
+}
+
+// importExchangeRates takes a CSV file uploaded as the file field of a
+// multipart form
+func importExchangeRates(context *gin.Context) {
+	header, err := context.FormFile("file")
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+	defer file.Close()
+
+	rates, err := model.ImportExchangeRates(file, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(rates),
+		Items:  rates,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func getExchangeRate(context *gin.Context) {
+	var form exchangeRateIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rate, err := model.GetExchangeRate(form.From, form.To, form.Date, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, rate)
+}
+
+func deleteExchangeRate(context *gin.Context) {
+	var form exchangeRateIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rate, err := model.DeleteExchangeRate(form.From, form.To, form.Date, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, rate)
+}
+
+func listExchangeRates(context *gin.Context) {
+	var form exchangeRateListForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rates, info, err := model.ListExchangeRates(
+		form.From,
+		form.To,
+		form.toPage(),
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(rates),
+		Items:      rates,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func convertAmount(context *gin.Context) {
+	var form exchangeRateConvertForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	amount, err := model.Convert(
+		form.Amount,
+		form.From,
+		form.To,
+		form.Date,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	data := map[string]interface{}{
+		"amount": amount,
+	}
+
+	buildSuccessContext(context, data)
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type goalIdentifyForm struct {
+	ID string `json:"id" binding:"required"`
+}
+
+type goalWalletForm struct {
+	Wallet string `json:"wallet"`
+	// Share is the percentage of the balance of the wallet which counts for
+	// the goal, the whole balance when not given
+	Share decimal.Decimal `json:"share"`
+}
+
+type goalCreateForm struct {
+	Name         string           `json:"name" binding:"required"`
+	TargetAmount decimal.Decimal  `json:"target_amount" binding:"required"`
+	TargetDate   *date.Date       `json:"target_date"`
+	Wallets      []goalWalletForm `json:"wallets" binding:"required"`
+}
+
+type goalUpdateForm struct {
+	ID string `json:"id" binding:"required"`
+	goalCreateForm
+}
+
+type goalProgressForm struct {
+	// ID, when given, is the only goal whose progress is returned
+	ID string `json:"id"`
+	// Months is how many months back the projection looks, three when not
+	// given
+	Months int `json:"months" binding:"min=0"`
+}
+
+func (form *goalCreateForm) toGoal() model.Goal {
+	wallets := make([]model.GoalWallet, len(form.Wallets))
+	for i, wallet := range form.Wallets {
+		wallets[i] = model.GoalWallet{
+			Wallet: wallet.Wallet,
+			Share:  wallet.Share,
+		}
+	}
+
+	return model.Goal{
+		Name:         form.Name,
+		TargetAmount: form.TargetAmount,
+		TargetDate:   form.TargetDate,
+		Wallets:      wallets,
+	}
+}
+
+func createGoal(context *gin.Context) {
+	var form goalCreateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	goal, err := model.CreateGoal(form.toGoal(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, goal)
+}
+
+func getGoal(context *gin.Context) {
+	var form goalIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	goal, err := model.GetGoal(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, goal)
+}
+
+func updateGoal(context *gin.Context) {
+	var form goalUpdateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	goal := form.toGoal()
+	goal.ID = form.ID
+
+	updated, err := model.UpdateGoal(goal, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, updated)
+}
+
+func deleteGoal(context *gin.Context) {
+	var form goalIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	goal, err := model.DeleteGoal(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, goal)
+}
+
+func listGoals(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	goals, info, err := model.ListGoals(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(goals),
+		Items:      goals,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func getGoalProgress(context *gin.Context) {
+	var form goalProgressForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	progress, err := model.GetGoalProgress(form.ID, form.Months, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(progress),
+		Items:  progress,
+	}
+
+	buildSuccessContext(context, items)
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+type ledgerCheckForm struct {
+	// Repair tells whether the issues found are repaired or only reported
+	Repair bool `json:"repair"`
+}
+
+func checkLedger(context *gin.Context) {
+	var form ledgerCheckForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	report, err := model.CheckLedger(form.Repair, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, report)
+}
+
+func listLedgerRepairs(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	repairs, info, err := model.ListLedgerRepairs(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(repairs),
+		Items:      repairs,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type reconciliationIdentifyForm struct {
+	ID string `json:"id" binding:"required"`
+}
+
+type reconciliationStartForm struct {
+	Wallet           string          `json:"wallet" binding:"required"`
+	StatementDate    date.Date       `json:"statement_date" binding:"required"`
+	StatementBalance decimal.Decimal `json:"statement_balance"`
+}
+
+type reconciliationTickForm struct {
+	ID             string   `json:"id" binding:"required"`
+	TransactionIDs []string `json:"transaction_ids" binding:"required"`
+	// Cleared tells whether the transactions are ticked or unticked
+	Cleared bool `json:"cleared"`
+}
+
+type reconciliationListForm struct {
+	Wallet string `json:"wallet"`
+	pageForm
+}
+
+func startReconciliation(context *gin.Context) {
+	var form reconciliationStartForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	r, err := model.StartReconciliation(
+		form.Wallet,
+		form.StatementDate,
+		form.StatementBalance,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, r)
+}
+
+func getReconciliation(context *gin.Context) {
+	var form reconciliationIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	r, err := model.GetReconciliation(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, r)
+}
+
+func tickReconciliation(context *gin.Context) {
+	var form reconciliationTickForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	r, err := model.TickReconciliation(
+		form.ID,
+		form.TransactionIDs,
+		form.Cleared,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, r)
+}
+
+func finishReconciliation(context *gin.Context) {
+	var form reconciliationIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	r, err := model.FinishReconciliation(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, r)
+}
+
+func cancelReconciliation(context *gin.Context) {
+	var form reconciliationIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	r, err := model.CancelReconciliation(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, r)
+}
+
+func listReconciliations(context *gin.Context) {
+	var form reconciliationListForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rs, info, err := model.ListReconciliations(
+		form.Wallet,
+		form.toPage(),
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(rs),
+		Items:      rs,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
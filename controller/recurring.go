@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type recurringIdentifyForm struct {
+	ID string `json:"id" binding:"required"`
+}
+
+type recurringCreateForm struct {
+	Type        constant.TransactionType `json:"type" binding:"required"`
+	From        string                   `json:"from"`
+	To          string                   `json:"to"`
+	Amount      decimal.Decimal          `json:"amount" binding:"required"`
+	DstAmount   *decimal.Decimal         `json:"dst_amount"`
+	Rate        *decimal.Decimal         `json:"rate"`
+	Category    string                   `json:"category" binding:"required"`
+	Description string                   `json:"description"`
+	Frequency   constant.Frequency       `json:"frequency" binding:"required"`
+	Interval    int                      `json:"interval" binding:"min=0"`
+	DayOfMonth  *int                     `json:"day_of_month"`
+	StartDate   date.Date                `json:"start_date"`
+	EndDate     *date.Date               `json:"end_date"`
+	Count       *int                     `json:"count"`
+}
+
+type recurringUpdateForm struct {
+	ID string `json:"id" binding:"required"`
+	recurringCreateForm
+}
+
+func (form *recurringCreateForm) toRule() model.RecurringRule {
+	return model.RecurringRule{
+		Type:        form.Type,
+		From:        form.From,
+		To:          form.To,
+		Amount:      form.Amount,
+		DstAmount:   form.DstAmount,
+		Rate:        form.Rate,
+		Category:    form.Category,
+		Description: form.Description,
+		Frequency:   form.Frequency,
+		Interval:    form.Interval,
+		DayOfMonth:  form.DayOfMonth,
+		StartDate:   form.StartDate,
+		EndDate:     form.EndDate,
+		Count:       form.Count,
+	}
+}
+
+func createRecurringRule(context *gin.Context) {
+	var form recurringCreateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rule, err := model.CreateRecurringRule(form.toRule(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, rule)
+}
+
+func getRecurringRule(context *gin.Context) {
+	var form recurringIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rule, err := model.GetRecurringRule(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, rule)
+}
+
+func updateRecurringRule(context *gin.Context) {
+	var form recurringUpdateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rule := form.toRule()
+	rule.ID = form.ID
+
+	updated, err := model.UpdateRecurringRule(rule, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, updated)
+}
+
+func deleteRecurringRule(context *gin.Context) {
+	var form recurringIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rule, err := model.DeleteRecurringRule(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, rule)
+}
+
+func listRecurringRules(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	rules, info, err := model.ListRecurringRules(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(rules),
+		Items:      rules,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func listFrequencies(context *gin.Context) {
+	frequencies := constant.ListFrequencies()
+	items := itemList{
+		Length: len(frequencies),
+		Items:  frequencies,
+	}
+
+	buildSuccessContext(context, items)
+}
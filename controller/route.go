@@ -22,31 +22,132 @@ func InitRoutes() *gin.Engine {
 	walletRoute := router.Group("/wallet")
 	categoryRoute := router.Group("/category")
 	transactionRoute := router.Group("/transaction")
+	recurringRoute := router.Group("/recurring")
+	exchangeRateRoute := router.Group("/exchangeRate")
+	tagRoute := router.Group("/tag")
+	attachmentRoute := router.Group("/attachment")
+	reconciliationRoute := router.Group("/reconciliation")
+	ledgerRoute := router.Group("/ledger")
+	budgetRoute := router.Group("/budget")
+	envelopeRoute := router.Group("/envelope")
+	goalRoute := router.Group("/goal")
 
 	walletRoute.Use(validateHeader)
 	categoryRoute.Use(validateHeader)
 	transactionRoute.Use(validateHeader)
+	recurringRoute.Use(validateHeader)
+	exchangeRateRoute.Use(validateHeader)
+	tagRoute.Use(validateHeader)
+	attachmentRoute.Use(validateHeader)
+	reconciliationRoute.Use(validateHeader)
+	ledgerRoute.Use(validateHeader)
+	budgetRoute.Use(validateHeader)
+	envelopeRoute.Use(validateHeader)
+	goalRoute.Use(validateHeader)
 
 	walletRoute.POST("/create", createWallet)
 	walletRoute.POST("/get", getWallet)
+	walletRoute.POST("/update", updateWallet)
+	walletRoute.POST("/setCredit", setWalletCredit)
+	walletRoute.POST("/creditStatus", getCreditStatus)
+	walletRoute.POST("/setOverdraft", setWalletOverdraft)
 	walletRoute.POST("/delete", deleteWallet)
+	walletRoute.POST("/restore", restoreWallet)
+	walletRoute.POST("/listTrash", listTrashedWallets)
 	walletRoute.POST("/list", listWallets)
+	walletRoute.POST("/balanceAt", getBalancesAt)
+	walletRoute.POST("/balanceHistory", getBalanceHistory)
 	walletRoute.POST("/listTypes", listWalletTypes)
+	walletRoute.POST("/listOverdraftPolicies", listOverdraftPolicies)
 	walletRoute.POST("/init", initWallets)
 
 	categoryRoute.POST("/create", createCategory)
 	categoryRoute.POST("/get", getCategory)
+	categoryRoute.POST("/setParent", setCategoryParent)
+	categoryRoute.POST("/setKind", setCategoryKind)
+	categoryRoute.POST("/rename", renameCategory)
+	categoryRoute.POST("/merge", mergeCategory)
 	categoryRoute.POST("/delete", deleteCategory)
+	categoryRoute.POST("/restore", restoreCategory)
+	categoryRoute.POST("/listTrash", listTrashedCategories)
 	categoryRoute.POST("/list", listCategories)
+	categoryRoute.POST("/totals", totalCategories)
+	categoryRoute.POST("/listKinds", listCategoryKinds)
 	categoryRoute.POST("/init", initCategories)
 
 	transactionRoute.POST("/createExpense", createExpense)
 	transactionRoute.POST("/createIncome", createIncome)
 	transactionRoute.POST("/createTransfer", createTransfer)
+	transactionRoute.POST("/update", updateTransaction)
 	transactionRoute.POST("/get", getTransaction)
 	transactionRoute.POST("/delete", deleteTransaction)
+	transactionRoute.POST("/restore", restoreTransaction)
+	transactionRoute.POST("/listTrash", listTrashedTransactions)
 	transactionRoute.POST("/list", listTransactions)
 	transactionRoute.POST("/listTypes", listTransactionTypes)
+	transactionRoute.POST("/listStatuses", listTransactionStatuses)
+	transactionRoute.POST("/setStatus", setTransactionsStatus)
+	transactionRoute.POST("/unlock", unlockTransaction)
+
+	recurringRoute.POST("/create", createRecurringRule)
+	recurringRoute.POST("/get", getRecurringRule)
+	recurringRoute.POST("/update", updateRecurringRule)
+	recurringRoute.POST("/delete", deleteRecurringRule)
+	recurringRoute.POST("/list", listRecurringRules)
+	recurringRoute.POST("/listFrequencies", listFrequencies)
+
+	exchangeRateRoute.POST("/set", setExchangeRate)
+	exchangeRateRoute.POST("/import", importExchangeRates)
+	exchangeRateRoute.POST("/get", getExchangeRate)
+	exchangeRateRoute.POST("/delete", deleteExchangeRate)
+	exchangeRateRoute.POST("/list", listExchangeRates)
+	exchangeRateRoute.POST("/convert", convertAmount)
+
+	tagRoute.POST("/create", createTag)
+	tagRoute.POST("/get", getTag)
+	tagRoute.POST("/update", updateTag)
+	tagRoute.POST("/delete", deleteTag)
+	tagRoute.POST("/list", listTags)
+	tagRoute.POST("/totals", totalTags)
+
+	attachmentRoute.POST("/upload", uploadAttachment)
+	attachmentRoute.POST("/download", downloadAttachment)
+	attachmentRoute.POST("/get", getAttachment)
+	attachmentRoute.POST("/delete", deleteAttachment)
+	attachmentRoute.POST("/list", listAttachments)
+
+	reconciliationRoute.POST("/start", startReconciliation)
+	reconciliationRoute.POST("/get", getReconciliation)
+	reconciliationRoute.POST("/tick", tickReconciliation)
+	reconciliationRoute.POST("/finish", finishReconciliation)
+	reconciliationRoute.POST("/cancel", cancelReconciliation)
+	reconciliationRoute.POST("/list", listReconciliations)
+
+	ledgerRoute.POST("/check", checkLedger)
+	ledgerRoute.POST("/listRepairs", listLedgerRepairs)
+
+	budgetRoute.POST("/create", createBudget)
+	budgetRoute.POST("/get", getBudget)
+	budgetRoute.POST("/update", updateBudget)
+	budgetRoute.POST("/delete", deleteBudget)
+	budgetRoute.POST("/list", listBudgets)
+	budgetRoute.POST("/progress", getBudgetProgress)
+
+	envelopeRoute.POST("/create", createEnvelope)
+	envelopeRoute.POST("/get", getEnvelope)
+	envelopeRoute.POST("/update", updateEnvelope)
+	envelopeRoute.POST("/delete", deleteEnvelope)
+	envelopeRoute.POST("/list", listEnvelopes)
+	envelopeRoute.POST("/assign", assignToEnvelope)
+	envelopeRoute.POST("/move", moveBetweenEnvelopes)
+	envelopeRoute.POST("/ledger", getEnvelopeLedger)
+
+	goalRoute.POST("/create", createGoal)
+	goalRoute.POST("/get", getGoal)
+	goalRoute.POST("/update", updateGoal)
+	goalRoute.POST("/delete", deleteGoal)
+	goalRoute.POST("/list", listGoals)
+	goalRoute.POST("/progress", getGoalProgress)
 
 	if configs.Mode != "PRODUCTION" {
 		walletRoute.POST("/clear", clearWallets)
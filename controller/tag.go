@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"github.com/expenseledger/web-service/model"
+	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/gin-gonic/gin"
+)
+
+type tagIDForm struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type tagUpdateForm struct {
+	Name    string `json:"name" binding:"required"`
+	NewName string `json:"new_name" binding:"required"`
+}
+
+type tagTotalForm struct {
+	Tags     []string  `json:"tags"`
+	FromDate date.Date `json:"from_date"`
+	ToDate   date.Date `json:"to_date"`
+}
+
+func createTag(context *gin.Context) {
+	var form tagIDForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	tag, err := model.CreateTag(form.Name, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, tag)
+}
+
+func getTag(context *gin.Context) {
+	var form tagIDForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	tag, err := model.GetTag(form.Name, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, tag)
+}
+
+func updateTag(context *gin.Context) {
+	var form tagUpdateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	tag, err := model.RenameTag(form.Name, form.NewName, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, tag)
+}
+
+func deleteTag(context *gin.Context) {
+	var form tagIDForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	tag, err := model.DeleteTag(form.Name, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, tag)
+}
+
+func listTags(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	tags, info, err := model.ListTags(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(tags),
+		Items:      tags,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func totalTags(context *gin.Context) {
+	var form tagTotalForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	totals, err := model.TotalTags(
+		form.Tags,
+		form.FromDate,
+		form.ToDate,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(totals),
+		Items:  totals,
+	}
+
+	buildSuccessContext(context, items)
+}
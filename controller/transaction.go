@@ -14,14 +14,43 @@ type txIdentifyForm struct {
 }
 
 type txListForm struct {
-	Wallet string `json:"wallet" binding:"required"`
+	Wallet      string                       `json:"wallet"`
+	FromDate    date.Date                    `json:"from_date"`
+	ToDate      date.Date                    `json:"to_date"`
+	Categories  []string                     `json:"categories"`
+	Types       []constant.TransactionType   `json:"types"`
+	MinAmount   *decimal.Decimal             `json:"min_amount"`
+	MaxAmount   *decimal.Decimal             `json:"max_amount"`
+	Description string                       `json:"description"`
+	Tags        []string                     `json:"tags"`
+	Statuses    []constant.TransactionStatus `json:"statuses"`
+	// BaseCurrency, when given, is the currency the amounts are also
+	// expressed in
+	BaseCurrency string `json:"base_currency"`
+	// Statement, which needs Wallet, tells whether each transaction carries
+	// the balance the wallet had right after it
+	Statement bool `json:"statement"`
+	pageForm
 }
 
 type txCreateForm struct {
 	Amount      decimal.Decimal `json:"amount" binding:"required"`
-	Category    string          `json:"category" binding:"required"`
+	Category    string          `json:"category"`
+	Splits      []model.Split   `json:"splits"`
+	Tags        []string        `json:"tags"`
 	Description string          `json:"description"`
 	Date        date.Date       `json:"date"`
+	Currency    string          `json:"currency"`
+}
+
+type txStatusForm struct {
+	IDs    []string                   `json:"ids" binding:"required"`
+	Status constant.TransactionStatus `json:"status" binding:"required"`
+}
+
+type txRateForm struct {
+	DstAmount *decimal.Decimal `json:"dst_amount"`
+	Rate      *decimal.Decimal `json:"rate"`
 }
 
 type txExpenseForm struct {
@@ -38,6 +67,18 @@ type txTransferForm struct {
 	From string `json:"from" binding:"required"`
 	To   string `json:"to" binding:"required"`
 	txCreateForm
+	txRateForm
+}
+
+type txUpdateForm struct {
+	ID   string                   `json:"id" binding:"required"`
+	Type constant.TransactionType `json:"type" binding:"required"`
+	From string                   `json:"from"`
+	To   string                   `json:"to"`
+	// Tags are added to the transaction after RemoveTags are taken off
+	RemoveTags []string `json:"remove_tags"`
+	txCreateForm
+	txRateForm
 }
 
 func createExpense(context *gin.Context) {
@@ -52,29 +93,10 @@ func createExpense(context *gin.Context) {
 		return
 	}
 
-	wallet, err := model.GetWallet(form.From, userId)
-	if err != nil {
-		buildFailedContext(context, err)
-		return
-	}
-
-	tx, err := model.CreateTransction(
-		form.Amount,
-		constant.TransactionTypes().Expense,
-		form.From,
-		"",
-		form.Category,
-		form.Description,
-		form.Date,
-		userId,
-	)
+	input := form.toInput(constant.TransactionTypes().Expense, form.From, "")
 
-	if err != nil {
-		buildFailedContext(context, err)
-		return
-	}
+	tx, wallets, err := model.CreateTransction(input, userId)
 
-	err = wallet.Expend(tx)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
@@ -82,7 +104,7 @@ func createExpense(context *gin.Context) {
 
 	data := map[string]interface{}{
 		"transaction": tx,
-		"src_wallet":  wallet,
+		"src_wallet":  wallets.Src,
 	}
 
 	buildSuccessContext(context, data)
@@ -100,29 +122,10 @@ func createIncome(context *gin.Context) {
 		return
 	}
 
-	wallet, err := model.GetWallet(form.To, userId)
-	if err != nil {
-		buildFailedContext(context, err)
-		return
-	}
-
-	tx, err := model.CreateTransction(
-		form.Amount,
-		constant.TransactionTypes().Income,
-		"",
-		form.To,
-		form.Category,
-		form.Description,
-		form.Date,
-		userId,
-	)
+	input := form.toInput(constant.TransactionTypes().Income, "", form.To)
 
-	if err != nil {
-		buildFailedContext(context, err)
-		return
-	}
+	tx, wallets, err := model.CreateTransction(input, userId)
 
-	err = wallet.Receive(tx)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
@@ -130,7 +133,7 @@ func createIncome(context *gin.Context) {
 
 	data := map[string]interface{}{
 		"transaction": tx,
-		"dst_wallet":  wallet,
+		"dst_wallet":  wallets.Dst,
 	}
 
 	buildSuccessContext(context, data)
@@ -148,41 +151,45 @@ func createTransfer(context *gin.Context) {
 		return
 	}
 
-	srcWallet, err := model.GetWallet(form.From, userId)
+	input := form.toInput(constant.TransactionTypes().Transfer, form.From, form.To)
+	input.DstAmount = form.DstAmount
+	input.Rate = form.Rate
+
+	tx, wallets, err := model.CreateTransction(input, userId)
+
 	if err != nil {
 		buildFailedContext(context, err)
 		return
 	}
 
-	dstWallet, err := model.GetWallet(form.To, userId)
-	if err != nil {
-		buildFailedContext(context, err)
-		return
+	data := map[string]interface{}{
+		"transaction": tx,
+		"src_wallet":  wallets.Src,
+		"dst_wallet":  wallets.Dst,
 	}
 
-	tx, err := model.CreateTransction(
-		form.Amount,
-		constant.TransactionTypes().Transfer,
-		form.From,
-		form.To,
-		form.Category,
-		form.Description,
-		form.Date,
-		userId,
-	)
+	buildSuccessContext(context, data)
+}
 
-	if err != nil {
-		buildFailedContext(context, err)
+func updateTransaction(context *gin.Context) {
+	var form txUpdateForm
+	if err := bindJSON(context, &form); err != nil {
 		return
 	}
 
-	err = srcWallet.Expend(tx)
+	userId, err := pkg.GetUserId(context)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
 	}
 
-	err = dstWallet.Receive(tx)
+	input := form.toInput(form.Type, form.From, form.To)
+	input.DstAmount = form.DstAmount
+	input.Rate = form.Rate
+	input.RemoveTags = form.RemoveTags
+
+	tx, wallets, err := model.UpdateTransaction(form.ID, input, userId)
+
 	if err != nil {
 		buildFailedContext(context, err)
 		return
@@ -190,8 +197,8 @@ func createTransfer(context *gin.Context) {
 
 	data := map[string]interface{}{
 		"transaction": tx,
-		"src_wallet":  srcWallet,
-		"dst_wallet":  dstWallet,
+		"src_wallet":  wallets.Src,
+		"dst_wallet":  wallets.Dst,
 	}
 
 	buildSuccessContext(context, data)
@@ -251,15 +258,46 @@ func listTransactions(context *gin.Context) {
 		return
 	}
 
-	txs, err := model.ListTransactions(form.Wallet, userId)
+	filter := model.TransactionFilter{
+		Wallet:      form.Wallet,
+		FromDate:    form.FromDate,
+		ToDate:      form.ToDate,
+		Categories:  form.Categories,
+		Types:       form.Types,
+		MinAmount:   form.MinAmount,
+		MaxAmount:   form.MaxAmount,
+		Description: form.Description,
+		Statuses:    form.Statuses,
+		Tags:        form.Tags,
+	}
+
+	txs, info, err := model.ListTransactions(filter, form.toPage(), userId)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
 	}
 
+	if form.BaseCurrency != "" {
+		err = model.ConvertTransactions(txs, form.BaseCurrency, userId)
+		if err != nil {
+			buildFailedContext(context, err)
+			return
+		}
+	}
+
+	if form.Statement {
+		err = model.RunStatement(txs, form.Wallet, userId)
+		if err != nil {
+			buildFailedContext(context, err)
+			return
+		}
+	}
+
 	items := itemList{
-		Length: len(txs),
-		Items:  txs,
+		Length:     len(txs),
+		Items:      txs,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
 	}
 
 	buildSuccessContext(context, items)
@@ -275,6 +313,63 @@ func listTransactionTypes(context *gin.Context) {
 	buildSuccessContext(context, items)
 }
 
+func listTransactionStatuses(context *gin.Context) {
+	statuses := constant.ListTransactionStatuses()
+	items := itemList{
+		Length: len(statuses),
+		Items:  statuses,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func setTransactionsStatus(context *gin.Context) {
+	var form txStatusForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	err = model.SetTransactionsStatus(form.IDs, form.Status, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	data := map[string]interface{}{
+		"ids":    form.IDs,
+		"status": form.Status,
+	}
+
+	buildSuccessContext(context, data)
+}
+
+func unlockTransaction(context *gin.Context) {
+	var form txIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	tx, err := model.UnlockTransaction(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, tx)
+}
+
 func deleteTransaction(context *gin.Context) {
 	var form txIdentifyForm
 	if err := bindJSON(context, &form); err != nil {
@@ -287,41 +382,91 @@ func deleteTransaction(context *gin.Context) {
 		return
 	}
 
-	tx, err := model.DeleteTransaction(form.ID, userId)
+	tx, wallets, err := model.DeleteTransaction(form.ID, userId)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
 	}
 
-	var srcWallet, dstWallet *model.Wallet
-	if from := tx.From; from != "" {
-		srcWallet, err = model.GetWallet(from, userId)
-		if err != nil {
-			buildFailedContext(context, err)
-			return
-		}
-		if err = srcWallet.Receive(tx); err != nil {
-			buildFailedContext(context, err)
-			return
-		}
+	data := map[string]interface{}{
+		"transaction": tx,
+		"src_wallet":  wallets.Src,
+		"dst_wallet":  wallets.Dst,
 	}
-	if to := tx.To; to != "" {
-		dstWallet, err = model.GetWallet(to, userId)
-		if err != nil {
-			buildFailedContext(context, err)
-			return
-		}
-		if err = dstWallet.Expend(tx); err != nil {
-			buildFailedContext(context, err)
-			return
-		}
+
+	buildSuccessContext(context, data)
+}
+
+func restoreTransaction(context *gin.Context) {
+	var form txIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	tx, wallets, err := model.RestoreTransaction(form.ID, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
 	}
 
 	data := map[string]interface{}{
 		"transaction": tx,
-		"src_wallet":  srcWallet,
-		"dst_wallet":  dstWallet,
+		"src_wallet":  wallets.Src,
+		"dst_wallet":  wallets.Dst,
 	}
 
 	buildSuccessContext(context, data)
 }
+
+func listTrashedTransactions(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	txs, info, err := model.ListTrashedTransactions(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(txs),
+		Items:      txs,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func (form *txCreateForm) toInput(
+	t constant.TransactionType,
+	from string,
+	to string,
+) model.TransactionInput {
+	return model.TransactionInput{
+		Amount:      form.Amount,
+		Currency:    form.Currency,
+		Type:        t,
+		From:        from,
+		To:          to,
+		Category:    form.Category,
+		Splits:      form.Splits,
+		AddTags:     form.Tags,
+		Description: form.Description,
+		Date:        form.Date,
+	}
+}
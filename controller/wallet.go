@@ -4,20 +4,66 @@ import (
 	"github.com/expenseledger/web-service/constant"
 	"github.com/expenseledger/web-service/model"
 	"github.com/expenseledger/web-service/pkg"
+	"github.com/expenseledger/web-service/pkg/type/date"
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
 )
 
 type walletCreateForm struct {
-	Name    string              `json:"name" binding:"required"`
-	Type    constant.WalletType `json:"type" binding:"required"`
-	Balance decimal.Decimal     `json:"balance"`
+	Name     string              `json:"name" binding:"required"`
+	Type     constant.WalletType `json:"type" binding:"required"`
+	Balance  decimal.Decimal     `json:"balance"`
+	Currency string              `json:"currency"`
+}
+
+type walletListForm struct {
+	// BaseCurrency, when given, is the currency the balances are also
+	// expressed in
+	BaseCurrency string `json:"base_currency"`
+	pageForm
 }
 
 type walletIdentifyForm struct {
 	Name string `json:"name" binding:"required"`
 }
 
+type walletUpdateForm struct {
+	Name string `json:"name" binding:"required"`
+	// NewName, when given, is the name the wallet goes by from now on
+	NewName string              `json:"new_name"`
+	Type    constant.WalletType `json:"type"`
+}
+
+type walletCreditForm struct {
+	Name string `json:"name" binding:"required"`
+	// CreditLimit, StatementDay and DueDay replace the terms of the credit
+	// wallet, one not given is removed
+	CreditLimit  *decimal.Decimal `json:"credit_limit"`
+	StatementDay *int             `json:"statement_day"`
+	DueDay       *int             `json:"due_day"`
+}
+
+type walletOverdraftForm struct {
+	Name   string                   `json:"name" binding:"required"`
+	Policy constant.OverdraftPolicy `json:"policy" binding:"required"`
+	// Floor is the lowest balance a FLOOR policy allows
+	Floor *decimal.Decimal `json:"floor"`
+}
+
+type walletBalanceAtForm struct {
+	// Name, when given, is the only wallet whose balance is returned
+	Name string    `json:"name"`
+	Date date.Date `json:"date" binding:"required"`
+}
+
+type walletBalanceHistoryForm struct {
+	// Name, when given, is the only wallet whose balances are returned
+	Name      string             `json:"name"`
+	FromDate  date.Date          `json:"from_date" binding:"required"`
+	ToDate    date.Date          `json:"to_date" binding:"required"`
+	Frequency constant.Frequency `json:"frequency" binding:"required"`
+}
+
 func createWallet(context *gin.Context) {
 	var form walletCreateForm
 	if err := bindJSON(context, &form); err != nil {
@@ -30,7 +76,13 @@ func createWallet(context *gin.Context) {
 		return
 	}
 
-	wallet, err := model.CreateWallet(form.Name, form.Type, form.Balance, userId)
+	wallet, err := model.CreateWallet(
+		form.Name,
+		form.Type,
+		form.Balance,
+		form.Currency,
+		userId,
+	)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
@@ -82,22 +134,244 @@ func deleteWallet(context *gin.Context) {
 	buildSuccessContext(context, wallet)
 }
 
+func updateWallet(context *gin.Context) {
+	var form walletUpdateForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	wallet, err := model.UpdateWallet(
+		form.Name,
+		form.NewName,
+		form.Type,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, wallet)
+}
+
+func setWalletCredit(context *gin.Context) {
+	var form walletCreditForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	wallet, err := model.SetCreditTerms(
+		form.Name,
+		form.CreditLimit,
+		form.StatementDay,
+		form.DueDay,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, wallet)
+}
+
+func setWalletOverdraft(context *gin.Context) {
+	var form walletOverdraftForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	wallet, err := model.SetOverdraftPolicy(
+		form.Name,
+		form.Policy,
+		form.Floor,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, wallet)
+}
+
+func getCreditStatus(context *gin.Context) {
+	var form walletIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	status, err := model.GetCreditStatus(form.Name, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, status)
+}
+
+func restoreWallet(context *gin.Context) {
+	var form walletIdentifyForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	wallet, err := model.RestoreWallet(form.Name, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	buildSuccessContext(context, wallet)
+}
+
+func listTrashedWallets(context *gin.Context) {
+	var form pageForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	wallets, info, err := model.ListTrashedWallets(form.toPage(), userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length:     len(wallets),
+		Items:      wallets,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
 func listWallets(context *gin.Context) {
+	var form walletListForm
+	if err := bindOptionalJSON(context, &form); err != nil {
+		return
+	}
+
 	userId, err := pkg.GetUserId(context)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
 	}
 
-	wallets, err := model.ListWallets(userId)
+	wallets, info, err := model.ListWallets(form.toPage(), userId)
 	if err != nil {
 		buildFailedContext(context, err)
 		return
 	}
 
+	if form.BaseCurrency != "" {
+		err = model.ConvertWallets(wallets, form.BaseCurrency, userId)
+		if err != nil {
+			buildFailedContext(context, err)
+			return
+		}
+	}
+
 	items := itemList{
-		Length: len(wallets),
-		Items:  wallets,
+		Length:     len(wallets),
+		Items:      wallets,
+		NextCursor: info.NextCursor,
+		HasMore:    info.HasMore,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func getBalancesAt(context *gin.Context) {
+	var form walletBalanceAtForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	balances, err := model.GetBalancesAt(form.Name, form.Date, userId)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(balances),
+		Items:  balances,
+	}
+
+	buildSuccessContext(context, items)
+}
+
+func getBalanceHistory(context *gin.Context) {
+	var form walletBalanceHistoryForm
+	if err := bindJSON(context, &form); err != nil {
+		return
+	}
+
+	userId, err := pkg.GetUserId(context)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	balances, err := model.GetBalanceHistory(
+		form.Name,
+		form.FromDate,
+		form.ToDate,
+		form.Frequency,
+		userId,
+	)
+	if err != nil {
+		buildFailedContext(context, err)
+		return
+	}
+
+	items := itemList{
+		Length: len(balances),
+		Items:  balances,
 	}
 
 	buildSuccessContext(context, items)
@@ -113,6 +387,16 @@ func listWalletTypes(context *gin.Context) {
 	buildSuccessContext(context, items)
 }
 
+func listOverdraftPolicies(context *gin.Context) {
+	policies := constant.ListOverdraftPolicies()
+	items := itemList{
+		Length: len(policies),
+		Items:  policies,
+	}
+
+	buildSuccessContext(context, items)
+}
+
 func initWallets(context *gin.Context) {
 	userId, err := pkg.GetUserId(context)
 	if err != nil {
@@ -140,6 +424,7 @@ func initWallets(context *gin.Context) {
 			recipe.Name,
 			recipe.Type,
 			recipe.Balance,
+			"",
 			userId,
 		)
 		if err != nil {
@@ -18,11 +18,29 @@ import (
 const (
 	Transaction      = "transaction"
 	AffectedWallet   = "affected_wallet"
+	TransactionSplit = "transaction_split"
+	RecurringRule    = "recurring_rule"
+	RecurringPosting = "recurring_posting"
+	ExchangeRate     = "exchange_rate"
+	Tag              = "tag"
+	TransactionTag   = "transaction_tag"
+	Attachment       = "attachment"
+	Reconciliation   = "reconciliation"
+	LedgerRepair     = "ledger_repair"
+	Budget           = "budget"
+	Envelope         = "envelope"
+	EnvelopeEntry    = "envelope_entry"
+	Goal             = "goal"
+	GoalWallet       = "goal_wallet"
 	Category         = "category"
 	Wallet           = "wallet"
 	WalletTypes      = "wallet_type"
 	TransactionTypes = "transaction_type"
 	WalletRoles      = "wallet_role"
+	Frequencies      = "frequency"
+	TxStatuses       = "transaction_status"
+	CategoryKinds    = "category_kind"
+	Overdrafts       = "overdraft_policy"
 )
 
 var conn *sqlx.DB
@@ -79,6 +97,30 @@ func CreateTables() (err error) {
 		return
 	}
 
+	err = createFrequencyEnum()
+	if err != nil {
+		log.Println("Error creating enum:", Frequencies, err)
+		return
+	}
+
+	err = createTransactionStatusEnum()
+	if err != nil {
+		log.Println("Error creating enum:", TxStatuses, err)
+		return
+	}
+
+	err = createCategoryKindEnum()
+	if err != nil {
+		log.Println("Error creating enum:", CategoryKinds, err)
+		return
+	}
+
+	err = createOverdraftPolicyEnum()
+	if err != nil {
+		log.Println("Error creating enum:", Overdrafts, err)
+		return
+	}
+
 	err = createWalletTable()
 	if err != nil {
 		log.Println("Error creating table:", Wallet, err)
@@ -91,6 +133,12 @@ func CreateTables() (err error) {
 		return
 	}
 
+	err = createCategoryParentConstraint()
+	if err != nil {
+		log.Println("Error creating constraint:", Category, err)
+		return
+	}
+
 	err = createTransactionTable()
 	if err != nil {
 		log.Println("Error creating table:", Transaction, err)
@@ -103,11 +151,115 @@ func CreateTables() (err error) {
 		return
 	}
 
+	err = createTransactionSplitTable()
+	if err != nil {
+		log.Println("Error creating table:", TransactionSplit, err)
+		return
+	}
+
+	err = createRecurringRuleTable()
+	if err != nil {
+		log.Println("Error creating table:", RecurringRule, err)
+		return
+	}
+
+	err = createRecurringPostingTable()
+	if err != nil {
+		log.Println("Error creating table:", RecurringPosting, err)
+		return
+	}
+
+	err = createExchangeRateTable()
+	if err != nil {
+		log.Println("Error creating table:", ExchangeRate, err)
+		return
+	}
+
+	err = createTagTable()
+	if err != nil {
+		log.Println("Error creating table:", Tag, err)
+		return
+	}
+
+	err = createTransactionTagTable()
+	if err != nil {
+		log.Println("Error creating table:", TransactionTag, err)
+		return
+	}
+
+	err = createAttachmentTable()
+	if err != nil {
+		log.Println("Error creating table:", Attachment, err)
+		return
+	}
+
+	err = createReconciliationTable()
+	if err != nil {
+		log.Println("Error creating table:", Reconciliation, err)
+		return
+	}
+
+	err = createLedgerRepairTable()
+	if err != nil {
+		log.Println("Error creating table:", LedgerRepair, err)
+		return
+	}
+
+	err = backfillOpeningBalances()
+	if err != nil {
+		log.Println("Error backfilling opening balances:", Wallet, err)
+		return
+	}
+
+	err = createBudgetTable()
+	if err != nil {
+		log.Println("Error creating table:", Budget, err)
+		return
+	}
+
+	err = createEnvelopeTable()
+	if err != nil {
+		log.Println("Error creating table:", Envelope, err)
+		return
+	}
+
+	err = createEnvelopeEntryTable()
+	if err != nil {
+		log.Println("Error creating table:", EnvelopeEntry, err)
+		return
+	}
+
+	err = createGoalTable()
+	if err != nil {
+		log.Println("Error creating table:", Goal, err)
+		return
+	}
+
+	err = createGoalWalletTable()
+	if err != nil {
+		log.Println("Error creating table:", GoalWallet, err)
+		return
+	}
+
 	err = createTriggerSetUpdatedAt(
 		Wallet,
 		Category,
 		Transaction,
 		AffectedWallet,
+		TransactionSplit,
+		RecurringRule,
+		RecurringPosting,
+		ExchangeRate,
+		Tag,
+		TransactionTag,
+		Attachment,
+		Reconciliation,
+		LedgerRepair,
+		Budget,
+		Envelope,
+		EnvelopeEntry,
+		Goal,
+		GoalWallet,
 	)
 	if err != nil {
 		log.Println("Error creating trigger for updated_at", err)
@@ -119,19 +271,57 @@ func CreateTables() (err error) {
 
 func createCategoryTable() (err error) {
 	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (", Category)
-	query +=
+	query += fmt.Sprintf(
 		`
 		name character varying(20),
+		kind %s NOT NULL DEFAULT '%s',
+		parent character varying(20),
 		created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
 		updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		deleted_at timestamp with time zone,
 		user_id character varying(128),
 		PRIMARY KEY (user_id, name)
 		);
+		`,
+		CategoryKinds,
+		constant.CategoryKinds().Any,
+	)
+	query += fmt.Sprintf(
 		`
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS parent character varying(20);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS kind %s NOT NULL DEFAULT '%s';
+		`,
+		Category,
+		Category,
+		Category,
+		CategoryKinds,
+		constant.CategoryKinds().Any,
+	)
 	_, err = conn.Exec(query)
 	return
 }
 
+// createCategoryParentConstraint makes the parent of a category one of the
+// categories of the same user, it is a constraint of its own as a table made
+// before categories had parents only gets the column added
+func createCategoryParentConstraint() (err error) {
+	query := fmt.Sprintf(
+		`
+		ALTER TABLE %s
+		ADD CONSTRAINT category_parent_fkey
+		FOREIGN KEY (parent, user_id) REFERENCES %s (name, user_id);
+		`,
+		Category,
+		Category,
+	)
+	_, err = conn.Exec(query)
+	return filterError(err)
+}
+
 func createWalletTypeEnum() (err error) {
 	walletType := constant.WalletTypes()
 	query :=
@@ -173,6 +363,64 @@ func createWalletRoleEnum() (err error) {
 	return filterError(err)
 }
 
+func createFrequencyEnum() (err error) {
+	frequency := constant.Frequencies()
+	query :=
+		fmt.Sprintf(
+			"CREATE TYPE %s AS ENUM ('%s', '%s', '%s', '%s');",
+			Frequencies,
+			frequency.Daily,
+			frequency.Weekly,
+			frequency.Monthly,
+			frequency.Yearly,
+		)
+	_, err = conn.Exec(query)
+	return filterError(err)
+}
+
+func createCategoryKindEnum() (err error) {
+	categoryKind := constant.CategoryKinds()
+	query :=
+		fmt.Sprintf(
+			"CREATE TYPE %s AS ENUM ('%s', '%s', '%s', '%s');",
+			CategoryKinds,
+			categoryKind.Expense,
+			categoryKind.Income,
+			categoryKind.Transfer,
+			categoryKind.Any,
+		)
+	_, err = conn.Exec(query)
+	return filterError(err)
+}
+
+func createOverdraftPolicyEnum() (err error) {
+	policy := constant.OverdraftPolicies()
+	query :=
+		fmt.Sprintf(
+			"CREATE TYPE %s AS ENUM ('%s', '%s', '%s');",
+			Overdrafts,
+			policy.Disallow,
+			policy.Allow,
+			policy.Floor,
+		)
+	_, err = conn.Exec(query)
+	return filterError(err)
+}
+
+func createTransactionStatusEnum() (err error) {
+	txStatus := constant.TransactionStatuses()
+	query :=
+		fmt.Sprintf(
+			"CREATE TYPE %s AS ENUM ('%s', '%s', '%s');",
+			TxStatuses,
+			txStatus.Pending,
+			txStatus.Cleared,
+			txStatus.Reconciled,
+		)
+	_, err = conn.Exec(query)
+	return filterError(err)
+}
+
 func createWalletTable() (err error) {
 	query := fmt.Sprintf(
 		`
@@ -180,14 +428,48 @@ func createWalletTable() (err error) {
 			name character varying(20),
 			type %s NOT NULL,
 			balance NUMERIC(11, 2) NOT NULL DEFAULT 0.00,
+			opening_balance NUMERIC(11, 2),
+			currency character(3) NOT NULL DEFAULT '%s',
 			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			deleted_at timestamp with time zone,
 			user_id character varying(128),
 			PRIMARY KEY (name, user_id)
 		);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS currency character(3) NOT NULL DEFAULT '%s';
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS opening_balance NUMERIC(11, 2);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS credit_limit NUMERIC(11, 2)
+		CHECK (credit_limit >= 0);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS statement_day smallint
+		CHECK (statement_day BETWEEN 1 AND 31);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS due_day smallint
+		CHECK (due_day BETWEEN 1 AND 31);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS overdraft_policy %s NOT NULL DEFAULT '%s';
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS overdraft_floor NUMERIC(11, 2);
 		`,
 		Wallet,
 		WalletTypes,
+		config.GetConfigs().DefaultCurrency,
+		Wallet,
+		config.GetConfigs().DefaultCurrency,
+		Wallet,
+		Wallet,
+		Wallet,
+		Wallet,
+		Wallet,
+		Wallet,
+		Overdrafts,
+		constant.OverdraftPolicies().Allow,
+		Wallet,
 	)
 
 	_, err = conn.Exec(query)
@@ -206,16 +488,28 @@ func createTransactionTable() (err error) {
 			type %s NOT NULL,
 			category character varying(20) NOT NULL ,
 			description text NOT NULL DEFAULT '',
+			status %s NOT NULL DEFAULT '%s',
 			occurred_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			deleted_at timestamp with time zone,
 			user_id character varying(128),
 			FOREIGN KEY (category, user_id) REFERENCES %s (name, user_id)
 		);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS status %s NOT NULL DEFAULT '%s';
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
 		`,
 		Transaction,
 		TransactionTypes,
+		TxStatuses,
+		constant.TransactionStatuses().Pending,
 		Category,
+		Transaction,
+		TxStatuses,
+		constant.TransactionStatuses().Pending,
+		Transaction,
 	)
 
 	_, err = conn.Exec(query)
@@ -229,17 +523,429 @@ func createAffectedWalletTable() (err error) {
 			transaction_id uuid NOT NULL REFERENCES %s,
 			wallet character varying(20) NOT NULL,
 			role %s NOT NULL,
+			amount NUMERIC(11, 2) CHECK (amount >= 0),
 			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			user_id character varying(128),
 			PRIMARY KEY (transaction_id, wallet, role),
 			FOREIGN KEY (wallet, user_id) REFERENCES %s (name, user_id)
 		);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS amount NUMERIC(11, 2) CHECK (amount >= 0);
 		`,
 		AffectedWallet,
 		Transaction,
 		WalletRoles,
 		Wallet,
+		AffectedWallet,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createTransactionSplitTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			transaction_id uuid NOT NULL REFERENCES %s ON DELETE CASCADE,
+			line integer NOT NULL,
+			category character varying(20) NOT NULL,
+			amount NUMERIC(11, 2) NOT NULL DEFAULT 0.00 CHECK (amount >= 0),
+			note text NOT NULL DEFAULT '',
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			PRIMARY KEY (transaction_id, line),
+			FOREIGN KEY (category, user_id) REFERENCES %s (name, user_id)
+		);
+		`,
+		TransactionSplit,
+		Transaction,
+		Category,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createRecurringRuleTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+			type %s NOT NULL,
+			amount NUMERIC(11, 2) NOT NULL DEFAULT 0.00 CHECK (amount >= 0),
+			src_wallet character varying(20) NOT NULL DEFAULT '',
+			dst_wallet character varying(20) NOT NULL DEFAULT '',
+			category character varying(20) NOT NULL,
+			description text NOT NULL DEFAULT '',
+			frequency %s NOT NULL,
+			repeat_every integer NOT NULL DEFAULT 1 CHECK (repeat_every > 0),
+			day_of_month integer CHECK (day_of_month BETWEEN 1 AND 31),
+			start_date date NOT NULL,
+			end_date date,
+			max_occurrences integer CHECK (max_occurrences > 0),
+			occurrences integer NOT NULL DEFAULT 0,
+			next_date date,
+			last_date date,
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			FOREIGN KEY (category, user_id) REFERENCES %s (name, user_id)
+		);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS last_error text;
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS failed_at timestamp with time zone;
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS dst_amount NUMERIC(11, 2)
+		CHECK (dst_amount >= 0);
+		ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS rate NUMERIC(20, 10) CHECK (rate > 0);
+		`,
+		RecurringRule,
+		TransactionTypes,
+		Frequencies,
+		Category,
+		RecurringRule,
+		RecurringRule,
+		RecurringRule,
+		RecurringRule,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createRecurringPostingTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			rule_id uuid NOT NULL REFERENCES %s ON DELETE CASCADE,
+			occurred_on date NOT NULL,
+			transaction_id uuid NOT NULL,
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			PRIMARY KEY (rule_id, occurred_on)
+		);
+		`,
+		RecurringPosting,
+		RecurringRule,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createExchangeRateTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			src_currency character(3) NOT NULL,
+			dst_currency character(3) NOT NULL,
+			effective_on date NOT NULL,
+			rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			PRIMARY KEY (user_id, src_currency, dst_currency, effective_on),
+			CHECK (src_currency <> dst_currency)
+		);
+		`,
+		ExchangeRate,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createTagTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			name character varying(20),
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			PRIMARY KEY (user_id, name)
+		);
+		`,
+		Tag,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createTransactionTagTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			transaction_id uuid NOT NULL REFERENCES %s ON DELETE CASCADE,
+			tag character varying(20) NOT NULL,
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			PRIMARY KEY (transaction_id, tag),
+			FOREIGN KEY (tag, user_id) REFERENCES %s (name, user_id)
+			ON UPDATE CASCADE ON DELETE CASCADE
+		);
+		`,
+		TransactionTag,
+		Transaction,
+		Tag,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createAttachmentTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+			transaction_id uuid NOT NULL REFERENCES %s ON DELETE CASCADE,
+			file_name character varying(255) NOT NULL DEFAULT '',
+			content_type character varying(127) NOT NULL,
+			size bigint NOT NULL CHECK (size >= 0),
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128)
+		);
+		CREATE INDEX IF NOT EXISTS attachment_transaction_id_idx
+		ON %s (transaction_id);
+		`,
+		Attachment,
+		Transaction,
+		Attachment,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createReconciliationTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+			wallet character varying(20) NOT NULL,
+			statement_date date NOT NULL,
+			statement_balance NUMERIC(11, 2) NOT NULL,
+			reconciled_count integer NOT NULL DEFAULT 0,
+			finished_at timestamp with time zone,
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			FOREIGN KEY (wallet, user_id) REFERENCES %s (name, user_id)
+			ON DELETE CASCADE
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS reconciliation_open_idx
+		ON %s (wallet, user_id) WHERE finished_at IS NULL;
+		`,
+		Reconciliation,
+		Wallet,
+		Reconciliation,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+// backfillOpeningBalances sets the opening balance of each wallet created
+// before wallets had one to what its balance was before its transactions,
+// taking its stored balance as right. Any drift the stored balance had is
+// folded into the opening balance, where a ledger check cannot see it, so
+// each backfill leaves an audit record of what it was computed from.
+func backfillOpeningBalances() (err error) {
+	query := fmt.Sprintf(
+		`
+		WITH backfilled AS (
+			UPDATE %s w
+			SET opening_balance = w.balance - COALESCE((
+				SELECT SUM(
+					CASE WHEN a.role = 'SRC_WALLET'
+						THEN -COALESCE(a.amount, t.amount)
+						ELSE COALESCE(a.amount, t.amount)
+					END
+				)
+				FROM %s a, %s t
+				WHERE t.id = a.transaction_id
+				AND t.deleted_at IS NULL
+				AND a.wallet = w.name
+				AND a.user_id = w.user_id
+			), 0)
+			WHERE w.opening_balance IS NULL
+			RETURNING name, balance, opening_balance, user_id
+		)
+		INSERT INTO %s
+		(kind, wallet, old_balance, new_balance, detail, user_id)
+		SELECT
+		'%s', name, balance, balance,
+		'opening balance backfilled as ' || opening_balance ||
+		' from the stored balance, any drift it had is not seen',
+		user_id
+		FROM backfilled;
+		`,
+		Wallet,
+		AffectedWallet,
+		Transaction,
+		LedgerRepair,
+		constant.LedgerIssueKinds().OpeningBalance,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createLedgerRepairTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+			kind character varying(20) NOT NULL,
+			wallet character varying(20) NOT NULL DEFAULT '',
+			transaction_id uuid,
+			old_balance NUMERIC(11, 2),
+			new_balance NUMERIC(11, 2),
+			detail text NOT NULL DEFAULT '',
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128)
+		);
+		`,
+		LedgerRepair,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+// createBudgetTable keeps the categories of a budget by name, there is no
+// foreign key on an array so renaming, merging and purging categories see to
+// budgets themselves
+func createBudgetTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+			name character varying(50) NOT NULL DEFAULT '',
+			categories text[] NOT NULL,
+			period %s NOT NULL DEFAULT '%s',
+			amount NUMERIC(11, 2) NOT NULL CHECK (amount > 0),
+			currency character(3) NOT NULL DEFAULT '%s',
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128)
+		);
+		`,
+		Budget,
+		Frequencies,
+		constant.Frequencies().Monthly,
+		config.GetConfigs().DefaultCurrency,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+// createEnvelopeTable keeps the categories of an envelope by name, the same
+// way as those of a budget
+func createEnvelopeTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+			name character varying(50) NOT NULL,
+			categories text[] NOT NULL,
+			currency character(3) NOT NULL DEFAULT '%s',
+			start_month date NOT NULL,
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			UNIQUE (user_id, name)
+		);
+		`,
+		Envelope,
+		config.GetConfigs().DefaultCurrency,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createEnvelopeEntryTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+			envelope_id uuid NOT NULL REFERENCES %s ON DELETE CASCADE,
+			month date NOT NULL,
+			kind character varying(20) NOT NULL,
+			amount NUMERIC(11, 2) NOT NULL,
+			note text NOT NULL DEFAULT '',
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128)
+		);
+		CREATE INDEX IF NOT EXISTS envelope_entry_month_idx
+		ON %s (envelope_id, month);
+		`,
+		EnvelopeEntry,
+		Envelope,
+		EnvelopeEntry,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+func createGoalTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+			name character varying(50) NOT NULL,
+			target_amount NUMERIC(11, 2) NOT NULL CHECK (target_amount > 0),
+			target_date date,
+			currency character(3) NOT NULL DEFAULT '%s',
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			UNIQUE (user_id, name)
+		);
+		`,
+		Goal,
+		config.GetConfigs().DefaultCurrency,
+	)
+
+	_, err = conn.Exec(query)
+	return
+}
+
+// createGoalWalletTable links a goal to the wallets saved toward it, share
+// is the percentage of the balance of the wallet which counts for the goal
+func createGoalWalletTable() (err error) {
+	query := fmt.Sprintf(
+		`
+		CREATE TABLE IF NOT EXISTS %s (
+			goal_id uuid NOT NULL REFERENCES %s ON DELETE CASCADE,
+			wallet character varying(20) NOT NULL,
+			share NUMERIC(5, 2) NOT NULL DEFAULT 100
+			CHECK (share > 0 AND share <= 100),
+			created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id character varying(128),
+			PRIMARY KEY (goal_id, wallet),
+			FOREIGN KEY (wallet, user_id) REFERENCES %s (name, user_id)
+		);
+		`,
+		GoalWallet,
+		Goal,
+		Wallet,
 	)
 
 	_, err = conn.Exec(query)
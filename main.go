@@ -6,6 +6,7 @@ import (
 	"github.com/expenseledger/web-service/config"
 	"github.com/expenseledger/web-service/controller"
 	"github.com/expenseledger/web-service/db"
+	"github.com/expenseledger/web-service/service"
 	"github.com/shopspring/decimal"
 )
 
@@ -20,8 +21,11 @@ func main() {
 		log.Println("Successfully created tables")
 	}
 
-	router := controller.InitRoutes()
 	configs := config.GetConfigs()
+	go service.RunRecurringScheduler(configs.SchedulerInterval)
+	go service.RunTrashPurger(configs.SchedulerInterval, configs.TrashRetention)
+
+	router := controller.InitRoutes()
 
 	err = router.Run(":" + configs.Port)
 	if err != nil {
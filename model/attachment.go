@@ -0,0 +1,205 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/expenseledger/web-service/config"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/storage"
+)
+
+// Attachment the structure represents a file, such as a photo of a receipt,
+// attached to a transaction. Its content is kept in storage under its ID.
+type Attachment struct {
+	ID            string    `json:"id" db:"id"`
+	TransactionID string    `json:"transaction_id" db:"transaction_id"`
+	FileName      string    `json:"file_name" db:"file_name"`
+	ContentType   string    `json:"content_type" db:"content_type"`
+	Size          int64     `json:"size" db:"size"`
+	CreatedAt     time.Time `json:"-" db:"created_at"`
+	UserId        string    `json:"userId" db:"user_id"`
+}
+
+// attachmentTypes are the content types a file may be attached with
+var attachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// AddAttachment attaches the content of r to a transaction. The content type
+// is sniffed from the content, which may be no larger than the configured
+// limit. The content is stored before the attachment is committed, so an
+// attachment never lacks its content.
+func AddAttachment(
+	transactionID string,
+	fileName string,
+	r io.Reader,
+	userId string,
+) (*Attachment, error) {
+	maxSize := config.GetConfigs().AttachmentMaxSize
+	content, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("attachment must not exceed %d bytes", maxSize)
+	}
+
+	contentType := http.DetectContentType(content)
+	if !attachmentTypes[contentType] {
+		return nil, fmt.Errorf("%s cannot be attached", contentType)
+	}
+
+	var attachment *Attachment
+	err = inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		if _, err := applyToTx(uow, transactionID, one, userId); err != nil {
+			return err
+		}
+
+		a := Attachment{
+			TransactionID: transactionID,
+			FileName:      filepath.Base(filepath.Clean("/" + fileName)),
+			ContentType:   contentType,
+			Size:          int64(len(content)),
+			UserId:        userId,
+		}
+		if len(a.FileName) > 255 || a.FileName == "/" {
+			a.FileName = ""
+		}
+
+		mapper := orm.NewAttachmentMapper(uow, a)
+
+		tmp, err := mapper.Insert(&a)
+		if err != nil {
+			return err
+		}
+		attachment = tmp.(*Attachment)
+
+		return storage.Default().Put(attachment.ID, bytes.NewReader(content))
+	})
+	if err != nil {
+		if attachment != nil {
+			removeContents([]Attachment{*attachment})
+		}
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// GetAttachment returns matching attachment from DB
+func GetAttachment(id string, userId string) (*Attachment, error) {
+	return applyToAttachment(id, one, userId)
+}
+
+// OpenAttachment returns matching attachment along with its content, the
+// caller closes the content
+func OpenAttachment(
+	id string,
+	userId string,
+) (*Attachment, io.ReadCloser, error) {
+	attachment, err := applyToAttachment(id, one, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := storage.Default().Get(attachment.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return attachment, content, nil
+}
+
+// DeleteAttachment removes attachment from DB and then its content
+func DeleteAttachment(id string, userId string) (*Attachment, error) {
+	attachment, err := applyToAttachment(id, delete, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	removeContents([]Attachment{*attachment})
+	return attachment, nil
+}
+
+// ListAttachments returns the attachments of a transaction
+func ListAttachments(transactionID string, userId string) ([]Attachment, error) {
+	return listAttachments(nil, transactionID, userId)
+}
+
+func listAttachments(
+	uow *orm.UnitOfWork,
+	transactionID string,
+	userId string,
+) ([]Attachment, error) {
+	a := Attachment{TransactionID: transactionID, UserId: userId}
+	mapper := orm.NewAttachmentMapper(uow, a)
+
+	tmp, err := mapper.Many(&a)
+	if err != nil {
+		return nil, err
+	}
+
+	return *(tmp.(*[]Attachment)), nil
+}
+
+func listUserAttachments(
+	uow *orm.UnitOfWork,
+	userId string,
+) ([]Attachment, error) {
+	a := Attachment{UserId: userId}
+	mapper := orm.NewAttachmentMapper(uow, a)
+
+	tmp, err := mapper.OfUser(&a)
+	if err != nil {
+		return nil, err
+	}
+
+	return *(tmp.(*[]Attachment)), nil
+}
+
+// removeContents removes the content of attachments which are gone from DB.
+// It is called once they are gone for good, a content which cannot be
+// removed is only logged, as the attachment does not exist anymore.
+func removeContents(attachments []Attachment) {
+	store := storage.Default()
+	for _, attachment := range attachments {
+		if err := store.Delete(attachment.ID); err != nil {
+			log.Println("Error removing attachment", attachment.ID, err)
+		}
+	}
+}
+
+func applyToAttachment(
+	id string,
+	op operation,
+	userId string,
+) (*Attachment, error) {
+	a := Attachment{ID: id, UserId: userId}
+	mapper := orm.NewAttachmentMapper(nil, a)
+
+	var tmp interface{}
+	var err error
+	switch op {
+	case delete:
+		tmp, err = mapper.Delete(&a)
+	case one:
+		tmp, err = mapper.One(&a)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Attachment), nil
+}
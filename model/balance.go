@@ -0,0 +1,201 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// maxBalancePoints is the most dates a balance history may have per wallet
+const maxBalancePoints = 1000
+
+// BalancePoint the structure is the balance a wallet had at the end of a day,
+// counting the transactions which occurred by then
+type BalancePoint struct {
+	Wallet   string          `json:"wallet" db:"wallet"`
+	Currency string          `json:"currency" db:"currency"`
+	Date     date.Date       `json:"date" db:"date"`
+	Balance  decimal.Decimal `json:"balance" db:"balance"`
+	UserId   string          `json:"userId" db:"user_id"`
+}
+
+// pass this to ORM when asking for balances at dates
+type _BalanceDates struct {
+	Name   string         `db:"name"`
+	Dates  pq.StringArray `db:"dates"`
+	UserId string         `db:"user_id"`
+}
+
+// pass this to ORM when asking for running balances
+type _RunningBalance struct {
+	ID             string          `db:"id"`
+	RunningBalance decimal.Decimal `db:"running_balance"`
+}
+
+// pass this to ORM when asking for running balances
+type _RunningBalanceFilter struct {
+	Name   string         `db:"name"`
+	IDs    pq.StringArray `db:"ids"`
+	UserId string         `db:"user_id"`
+}
+
+// GetBalancesAt returns the balance of each wallet of the user, or of the
+// named one, at the end of a day
+func GetBalancesAt(
+	name string,
+	on date.Date,
+	userId string,
+) ([]BalancePoint, error) {
+	if time.Time(on).IsZero() {
+		return nil, errors.New("date is required")
+	}
+	return balancesAt(name, []time.Time{time.Time(on)}, userId)
+}
+
+// GetBalanceHistory returns the balance of each wallet of the user, or of
+// the named one, at the end of each period between the dates, both
+// inclusive. A daily period is a day, a weekly one the seven days from
+// fromDate on, and a monthly or a yearly one a calendar month or year. The
+// last period ends on toDate.
+func GetBalanceHistory(
+	name string,
+	fromDate date.Date,
+	toDate date.Date,
+	frequency constant.Frequency,
+	userId string,
+) ([]BalancePoint, error) {
+	from, to := time.Time(fromDate), time.Time(toDate)
+	if from.IsZero() || to.IsZero() {
+		return nil, errors.New("from date and to date are required")
+	}
+	if to.Before(from) {
+		return nil, errors.New("to date must not be before from date")
+	}
+	if !isFrequency(frequency) {
+		return nil, errors.New("unknown frequency")
+	}
+
+	days, err := periodEnds(toDay(from), toDay(to), frequency)
+	if err != nil {
+		return nil, err
+	}
+
+	return balancesAt(name, days, userId)
+}
+
+// RunStatement sets the running balance of the transactions of a wallet,
+// the balance the wallet had right after each of them
+func RunStatement(txs []Transaction, wallet string, userId string) error {
+	if wallet == "" {
+		return errors.New("a statement is of one wallet, wallet is required")
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+
+	filter := _RunningBalanceFilter{
+		Name:   wallet,
+		IDs:    make(pq.StringArray, len(txs)),
+		UserId: userId,
+	}
+	for i, tx := range txs {
+		filter.IDs[i] = tx.ID
+	}
+
+	mapper := orm.NewWalletMapper(nil, _RunningBalance{})
+
+	tmp, err := mapper.RunningBalances(&filter)
+	if err != nil {
+		return err
+	}
+
+	balances := make(map[string]decimal.Decimal)
+	for _, balance := range *(tmp.(*[]_RunningBalance)) {
+		balances[balance.ID] = balance.RunningBalance
+	}
+
+	for i := range txs {
+		if balance, ok := balances[txs[i].ID]; ok {
+			txs[i].RunningBalance = &balance
+		}
+	}
+
+	return nil
+}
+
+func balancesAt(
+	name string,
+	days []time.Time,
+	userId string,
+) ([]BalancePoint, error) {
+	if name != "" {
+		if _, err := applyToWallet(nil, name, one, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	dates := _BalanceDates{
+		Name:   name,
+		Dates:  make(pq.StringArray, len(days)),
+		UserId: userId,
+	}
+	for i, day := range days {
+		dates.Dates[i] = day.Format("2006-01-02")
+	}
+
+	mapper := orm.NewWalletMapper(nil, BalancePoint{})
+
+	tmp, err := mapper.BalancesAt(&dates)
+	if err != nil {
+		return nil, err
+	}
+
+	return *(tmp.(*[]BalancePoint)), nil
+}
+
+// periodEnds returns the last day of each period of frequency from from on,
+// the last period is cut short to end on to
+func periodEnds(
+	from time.Time,
+	to time.Time,
+	frequency constant.Frequency,
+) ([]time.Time, error) {
+	frequencies := constant.Frequencies()
+
+	var days []time.Time
+	for start := from; !start.After(to); {
+		var next time.Time
+		switch frequency {
+		case frequencies.Daily:
+			next = start.AddDate(0, 0, 1)
+		case frequencies.Weekly:
+			next = start.AddDate(0, 0, 7)
+		case frequencies.Monthly:
+			next = time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case frequencies.Yearly:
+			next = time.Date(start.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+
+		end := next.AddDate(0, 0, -1)
+		if end.After(to) {
+			end = to
+		}
+		days = append(days, end)
+
+		if len(days) > maxBalancePoints {
+			return nil, fmt.Errorf(
+				"a balance history may have at most %d periods",
+				maxBalancePoints,
+			)
+		}
+		start = next
+	}
+
+	return days, nil
+}
@@ -0,0 +1,347 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/expenseledger/web-service/config"
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// maxBudgetPeriods is the most periods the progress of a budget may go back
+const maxBudgetPeriods = 36
+
+// Budget the structure is the most a user means to spend on some categories,
+// and on the categories below them, in each calendar day, week, month or year.
+// Only expenses from wallets in the currency of the budget count toward it.
+type Budget struct {
+	ID         string             `json:"id" db:"id"`
+	Name       string             `json:"name" db:"name"`
+	Categories pq.StringArray     `json:"categories" db:"categories"`
+	Period     constant.Frequency `json:"period" db:"period"`
+	Amount     decimal.Decimal    `json:"amount" db:"amount"`
+	Currency   string             `json:"currency" db:"currency"`
+	CreatedAt  time.Time          `json:"-" db:"created_at"`
+	UserId     string             `json:"userId" db:"user_id"`
+}
+
+// BudgetProgress the structure is how much of a budget was spent in one of
+// its periods, both ends of which are inclusive
+type BudgetProgress struct {
+	BudgetID    string             `json:"budget_id"`
+	Name        string             `json:"name"`
+	Currency    string             `json:"currency"`
+	PeriodStart date.Date          `json:"period_start"`
+	PeriodEnd   date.Date          `json:"period_end"`
+	Amount      decimal.Decimal    `json:"amount"`
+	Spent       decimal.Decimal    `json:"spent"`
+	Remaining   decimal.Decimal    `json:"remaining"`
+	Percentage  decimal.Decimal    `json:"percentage"`
+	OverBudget  bool               `json:"over_budget"`
+	Categories  []CategorySpending `json:"categories"`
+}
+
+// CategorySpending the structure is how much was spent on one of the
+// categories of a budget in a period
+type CategorySpending struct {
+	Category string          `json:"category" db:"category"`
+	Spent    decimal.Decimal `json:"spent" db:"spent"`
+}
+
+// pass this to ORM when summing up what was spent on a budget
+type _BudgetSpentFilter struct {
+	Categories pq.StringArray `db:"categories"`
+	Currency   string         `db:"currency"`
+	FromDate   time.Time      `db:"from_date"`
+	ToDate     time.Time      `db:"to_date"`
+	MaxDepth   int            `db:"max_depth"`
+	UserId     string         `db:"user_id"`
+}
+
+// CreateBudget inserts budget to DB
+func CreateBudget(budget Budget, userId string) (*Budget, error) {
+	budget.UserId = userId
+	if err := budget.check(); err != nil {
+		return nil, err
+	}
+
+	mapper := orm.NewBudgetMapper(nil, budget)
+
+	tmp, err := mapper.Insert(&budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Budget), nil
+}
+
+// GetBudget returns matching budget from DB
+func GetBudget(id string, userId string) (*Budget, error) {
+	return applyToBudget(id, one, userId)
+}
+
+// UpdateBudget rewrites the name, categories, period, amount and currency of
+// a budget
+func UpdateBudget(budget Budget, userId string) (*Budget, error) {
+	budget.UserId = userId
+	if err := budget.check(); err != nil {
+		return nil, err
+	}
+
+	mapper := orm.NewBudgetMapper(nil, budget)
+
+	tmp, err := mapper.Update(&budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Budget), nil
+}
+
+// DeleteBudget removes budget from DB
+func DeleteBudget(id string, userId string) (*Budget, error) {
+	return applyToBudget(id, delete, userId)
+}
+
+// ListBudgets returns a page of the budgets of the user
+func ListBudgets(page Page, userId string) ([]Budget, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewBudgetMapper(nil, Budget{})
+
+	tmp, err := mapper.Many(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	budgets := *(tmp.(*[]Budget))
+	length, info := page.cut(len(budgets), func(i int) cursor {
+		return cursor{CreatedAt: budgets[i].CreatedAt, Key: budgets[i].ID}
+	})
+
+	return budgets[:length], info, nil
+}
+
+// GetBudgetProgress returns how much of a budget, or of each budget of the
+// user when id is empty, was spent in the period the day falls in and in the
+// periods before it, most recent first. A zero day stands for today and a
+// zero number of periods for only the one the day falls in.
+func GetBudgetProgress(
+	id string,
+	on date.Date,
+	periods int,
+	userId string,
+) ([]BudgetProgress, error) {
+	if periods == 0 {
+		periods = 1
+	}
+	if periods < 0 || periods > maxBudgetPeriods {
+		return nil, fmt.Errorf(
+			"periods must be between 1 and %d",
+			maxBudgetPeriods,
+		)
+	}
+
+	day := toDay(time.Time(on))
+	if time.Time(on).IsZero() {
+		day = toDay(time.Now())
+	}
+
+	var budgets []Budget
+	if id != "" {
+		budget, err := applyToBudget(id, one, userId)
+		if err != nil {
+			return nil, err
+		}
+		budgets = []Budget{*budget}
+	} else {
+		mapper := orm.NewBudgetMapper(nil, Budget{})
+
+		tmp, err := mapper.All(&Budget{UserId: userId})
+		if err != nil {
+			return nil, err
+		}
+		budgets = *(tmp.(*[]Budget))
+	}
+
+	progress := []BudgetProgress{}
+	for _, budget := range budgets {
+		start := periodStart(day, budget.Period)
+		for i := 0; i < periods; i++ {
+			next := nextPeriodStart(start, budget.Period)
+
+			p, err := budget.progress(start, next)
+			if err != nil {
+				return nil, err
+			}
+			progress = append(progress, *p)
+
+			start = previousPeriodStart(start, budget.Period)
+		}
+	}
+
+	return progress, nil
+}
+
+// progress sums up what was spent on the budget from start until end
+func (budget *Budget) progress(
+	start time.Time,
+	end time.Time,
+) (*BudgetProgress, error) {
+	filter := _BudgetSpentFilter{
+		Categories: budget.Categories,
+		Currency:   budget.Currency,
+		FromDate:   start,
+		ToDate:     end,
+		MaxDepth:   maxCategoryDepth,
+		UserId:     budget.UserId,
+	}
+	mapper := orm.NewBudgetMapper(nil, CategorySpending{})
+
+	tmp, err := mapper.Spent(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := BudgetProgress{
+		BudgetID:    budget.ID,
+		Name:        budget.Name,
+		Currency:    budget.Currency,
+		PeriodStart: date.Date(start),
+		PeriodEnd:   date.Date(end.AddDate(0, 0, -1)),
+		Amount:      budget.Amount,
+		Spent:       decimal.Zero,
+		Categories:  *(tmp.(*[]CategorySpending)),
+	}
+	for _, spending := range p.Categories {
+		p.Spent = p.Spent.Add(spending.Spent)
+	}
+
+	p.Remaining = p.Amount.Sub(p.Spent)
+	p.Percentage = p.Spent.Mul(decimal.New(100, 0)).Div(p.Amount).Round(2)
+	p.OverBudget = p.Spent.GreaterThan(p.Amount)
+
+	return &p, nil
+}
+
+// check validates the budget and fills in its defaults, its categories must
+// exist and be for expenses
+func (budget *Budget) check() error {
+	if len(budget.Categories) == 0 {
+		return errors.New("a budget needs at least one category")
+	}
+
+	seen := make(map[string]bool)
+	categories := pq.StringArray{}
+	for _, category := range budget.Categories {
+		if seen[category] {
+			continue
+		}
+		seen[category] = true
+		categories = append(categories, category)
+	}
+	budget.Categories = categories
+
+	err := checkCategories(
+		nil,
+		budget.Categories,
+		constant.TransactionTypes().Expense,
+		budget.UserId,
+	)
+	if err != nil {
+		return err
+	}
+
+	if budget.Period == "" {
+		budget.Period = constant.Frequencies().Monthly
+	}
+	if !isFrequency(budget.Period) {
+		return errors.New("unknown period")
+	}
+
+	if budget.Amount.Sign() <= 0 {
+		return errors.New("amount must be positive")
+	}
+
+	if budget.Currency == "" {
+		budget.Currency = config.GetConfigs().DefaultCurrency
+	}
+	if !currencyCode.MatchString(budget.Currency) {
+		return errors.New("currency must be an ISO 4217 code")
+	}
+
+	return nil
+}
+
+// periodStart returns the first day of the calendar period of frequency the
+// day falls in, a week starts on Monday
+func periodStart(day time.Time, frequency constant.Frequency) time.Time {
+	frequencies := constant.Frequencies()
+
+	switch frequency {
+	case frequencies.Weekly:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case frequencies.Monthly:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case frequencies.Yearly:
+		return time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func nextPeriodStart(start time.Time, frequency constant.Frequency) time.Time {
+	return shiftPeriod(start, frequency, 1)
+}
+
+func previousPeriodStart(
+	start time.Time,
+	frequency constant.Frequency,
+) time.Time {
+	return shiftPeriod(start, frequency, -1)
+}
+
+func shiftPeriod(
+	start time.Time,
+	frequency constant.Frequency,
+	n int,
+) time.Time {
+	frequencies := constant.Frequencies()
+
+	switch frequency {
+	case frequencies.Weekly:
+		return start.AddDate(0, 0, 7*n)
+	case frequencies.Monthly:
+		return start.AddDate(0, n, 0)
+	case frequencies.Yearly:
+		return start.AddDate(n, 0, 0)
+	}
+	return start.AddDate(0, 0, n)
+}
+
+func applyToBudget(id string, op operation, userId string) (*Budget, error) {
+	budget := Budget{ID: id, UserId: userId}
+	mapper := orm.NewBudgetMapper(nil, budget)
+
+	var tmp interface{}
+	var err error
+	switch op {
+	case delete:
+		tmp, err = mapper.Delete(&budget)
+	case one:
+		tmp, err = mapper.One(&budget)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Budget), nil
+}
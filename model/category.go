@@ -1,33 +1,400 @@
 package model
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
 	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
 )
 
-// Category the structure represents a category in presentation layer
+// maxCategoryDepth is the most levels a tree of categories may have
+const maxCategoryDepth = 5
+
+// Category the structure represents a category in presentation layer, a
+// category may be filed under a parent category. Its kind is the type of
+// transactions which may be filed under it.
 type Category struct {
-	Name   string `json:"name" db:"name"`
-	UserId string `json:"userId" db:"user_id"`
+	Name      string                `json:"name" db:"name"`
+	Kind      constant.CategoryKind `json:"kind" db:"kind"`
+	Parent    *string               `json:"parent" db:"parent"`
+	Children  []Category            `json:"children,omitempty" db:"-"`
+	CreatedAt time.Time             `json:"-" db:"created_at"`
+	DeletedAt *time.Time            `json:"deleted_at,omitempty" db:"deleted_at"`
+	UserId    string                `json:"userId" db:"user_id"`
+}
+
+// CategoryTotal the structure sums up the transactions of a category and of
+// the categories below it in one currency
+type CategoryTotal struct {
+	Category string          `json:"category" db:"category"`
+	Currency string          `json:"currency" db:"currency"`
+	Count    int             `json:"count" db:"count"`
+	Expense  decimal.Decimal `json:"expense" db:"expense"`
+	Income   decimal.Decimal `json:"income" db:"income"`
+	Transfer decimal.Decimal `json:"transfer" db:"transfer"`
+}
+
+// pass this to ORM when walking a tree of categories
+type _CategoryNode struct {
+	Name      string                `db:"name"`
+	Kind      constant.CategoryKind `db:"kind"`
+	Parent    *string               `db:"parent"`
+	CreatedAt time.Time             `db:"created_at"`
+	DeletedAt *time.Time            `db:"deleted_at"`
+	UserId    string                `db:"user_id"`
+	Depth     int                   `db:"depth"`
+}
+
+// pass this to ORM when walking a tree of categories
+type _CategoryTree struct {
+	Names    pq.StringArray `db:"names"`
+	MaxDepth int            `db:"max_depth"`
+	UserId   string         `db:"user_id"`
+}
+
+// pass this to ORM when summing up categories
+type _CategoryTotalFilter struct {
+	FromDate   *time.Time     `db:"from_date"`
+	ToDate     *time.Time     `db:"to_date"`
+	Categories pq.StringArray `db:"categories"`
+	MaxDepth   int            `db:"max_depth"`
+	UserId     string         `db:"user_id"`
+}
+
+// CreateCategory inserts category to DB, under parent unless it is empty. An
+// empty kind stands for a category of any kind.
+func CreateCategory(
+	name string,
+	kind constant.CategoryKind,
+	parent string,
+	userId string,
+) (*Category, error) {
+	if kind == "" {
+		kind = constant.CategoryKinds().Any
+	}
+	if !isCategoryKind(kind) {
+		return nil, errors.New("unknown category kind")
+	}
+
+	var category *Category
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		c := Category{Name: name, Kind: kind, UserId: userId}
+		if parent != "" {
+			if err := checkParent(uow, name, parent, userId); err != nil {
+				return err
+			}
+			c.Parent = &parent
+		}
+		mapper := orm.NewCategoryMapper(uow, c)
+
+		tmp, err := mapper.Insert(&c)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errors.New(
+				"category already exists, it may be in the trash",
+			)
+		}
+		if err != nil {
+			return err
+		}
+
+		category = tmp.(*Category)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
 }
 
-// CreateCategory inserts category to DB
-func CreateCategory(name string, userId string) (*Category, error) {
-	return applyToCategory(name, insert, userId)
+// SetCategoryParent files category under parent, or at the top when parent is
+// empty, along with the categories below it
+func SetCategoryParent(
+	name string,
+	parent string,
+	userId string,
+) (*Category, error) {
+	var category *Category
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		c := Category{Name: name, UserId: userId}
+		if parent != "" {
+			if err := checkParent(uow, name, parent, userId); err != nil {
+				return err
+			}
+			c.Parent = &parent
+		}
+		mapper := orm.NewCategoryMapper(uow, c)
+
+		tmp, err := mapper.Update(&c)
+		if err != nil {
+			return err
+		}
+
+		category = tmp.(*Category)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
 }
 
 // GetCategory returns matching category from DB
 func GetCategory(name string, userId string) (*Category, error) {
-	return applyToCategory(name, one, userId)
+	return applyToCategory(nil, name, one, userId)
 }
 
-// DeleteCategory removes category from DB
+// DeleteCategory moves category to the trash, the transactions filed under
+// it keep it
 func DeleteCategory(name string, userId string) (*Category, error) {
-	return applyToCategory(name, delete, userId)
+	return applyToCategory(nil, name, delete, userId)
+}
+
+// RestoreCategory takes category out of the trash
+func RestoreCategory(name string, userId string) (*Category, error) {
+	return applyToCategory(nil, name, restore, userId)
+}
+
+// SetCategoryKind sets the type of transactions which may be filed under
+// category from now on, those filed under it already are left as they are
+func SetCategoryKind(
+	name string,
+	kind constant.CategoryKind,
+	userId string,
+) (*Category, error) {
+	if !isCategoryKind(kind) {
+		return nil, errors.New("unknown category kind")
+	}
+
+	c := Category{Name: name, Kind: kind, UserId: userId}
+	mapper := orm.NewCategoryMapper(nil, c)
+
+	tmp, err := mapper.SetKind(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Category), nil
+}
+
+// CategoryMove the structure reports what was moved from one category to
+// another when the category was renamed or merged into the other
+type CategoryMove struct {
+	From           string `json:"from" db:"name"`
+	To             string `json:"to" db:"new_name"`
+	Transactions   int    `json:"transactions" db:"transactions"`
+	Splits         int    `json:"splits" db:"splits"`
+	RecurringRules int    `json:"recurring_rules" db:"recurring_rules"`
+	Subcategories  int    `json:"subcategories" db:"subcategories"`
+	Budgets        int    `json:"budgets" db:"budgets"`
+	Envelopes      int    `json:"envelopes" db:"envelopes"`
+	UserId         string `json:"userId" db:"user_id"`
+}
+
+// pass this to ORM when moving a category
+type _CategoryMove struct {
+	Name    string `db:"name"`
+	NewName string `db:"new_name"`
+	UserId  string `db:"user_id"`
+}
+
+// RenameCategory renames category, the transactions, splits, recurring rules
+// and subcategories filed under it, and the budgets and envelopes on it, are
+// moved to the new name, all in one unit of work
+func RenameCategory(
+	name string,
+	newName string,
+	userId string,
+) (*CategoryMove, error) {
+	if newName == name {
+		return nil, errors.New("new name must differ from name")
+	}
+
+	var move *CategoryMove
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		_move := _CategoryMove{Name: name, NewName: newName, UserId: userId}
+		mapper := orm.NewCategoryMapper(uow, Category{})
+
+		_, err := mapper.Rename(&_move)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errors.New(
+				"category already exists, it may be in the trash",
+			)
+		}
+		if err != nil {
+			return err
+		}
+
+		move, err = moveCategory(uow, _move)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return move, nil
 }
 
-// ListCategories ...
-func ListCategories(userId string) ([]Category, error) {
-	return applyToCategories(list, userId)
+// MergeCategory moves the transactions, splits, recurring rules and
+// subcategories filed under category, and the budgets and envelopes on it,
+// into another existing one of the same kind, or of any kind, and then
+// removes category, all in one unit of work
+func MergeCategory(
+	name string,
+	into string,
+	userId string,
+) (*CategoryMove, error) {
+	if into == name {
+		return nil, errors.New("a category cannot be merged into itself")
+	}
+
+	var move *CategoryMove
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		// the subcategories of category end up one level below into
+		depth, height, err := placeCategory(uow, name, into, userId)
+		if err != nil {
+			return err
+		}
+		if depth+height-1 > maxCategoryDepth {
+			return fmt.Errorf(
+				"categories may be at most %d levels deep",
+				maxCategoryDepth,
+			)
+		}
+		from, err := applyToCategory(uow, name, one, userId)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("category %s does not exist", name)
+		}
+		if err != nil {
+			return err
+		}
+		// into has to take whatever may be filed under category, a category
+		// of any kind can only go into another one of any kind
+		err = checkCategories(
+			uow,
+			[]string{into},
+			constant.TransactionType(from.Kind),
+			userId,
+		)
+		if err != nil {
+			return err
+		}
+
+		move, err = moveCategory(
+			uow,
+			_CategoryMove{Name: name, NewName: into, UserId: userId},
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return move, nil
+}
+
+// ListCategories returns a page of the categories of the user at the top of
+// their trees, each with the categories below it
+func ListCategories(page Page, userId string) ([]Category, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewCategoryMapper(nil, Category{})
+
+	tmp, err := mapper.Many(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	categories := *(tmp.(*[]Category))
+	length, info := page.cut(len(categories), func(i int) cursor {
+		return cursor{
+			CreatedAt: categories[i].CreatedAt,
+			Key:       categories[i].Name,
+		}
+	})
+
+	categories = categories[:length]
+	if err := growCategories(categories, userId); err != nil {
+		return nil, nil, err
+	}
+
+	return categories, info, nil
+}
+
+// TotalCategories sums up the transactions of each category, or of the given
+// categories, which occurred between the dates, both inclusive. The total of
+// a category takes in those of the categories below it, and a category has a
+// total for each currency its transactions are in.
+func TotalCategories(
+	categories []string,
+	fromDate date.Date,
+	toDate date.Date,
+	userId string,
+) ([]CategoryTotal, error) {
+	filter := _CategoryTotalFilter{
+		MaxDepth: maxCategoryDepth,
+		UserId:   userId,
+	}
+
+	if from := time.Time(fromDate); !from.IsZero() {
+		filter.FromDate = &from
+	}
+	if to := time.Time(toDate); !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+		filter.ToDate = &to
+	}
+	if len(categories) > 0 {
+		filter.Categories = pq.StringArray(categories)
+	}
+
+	mapper := orm.NewCategoryMapper(nil, CategoryTotal{})
+
+	tmp, err := mapper.Totals(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return *(tmp.(*[]CategoryTotal)), nil
+}
+
+// ListTrashedCategories returns a page of the categories of the user in the
+// trash
+func ListTrashedCategories(
+	page Page,
+	userId string,
+) ([]Category, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewCategoryMapper(nil, Category{})
+
+	tmp, err := mapper.Trash(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	categories := *(tmp.(*[]Category))
+	length, info := page.cut(len(categories), func(i int) cursor {
+		return cursor{
+			CreatedAt: categories[i].CreatedAt,
+			Key:       categories[i].Name,
+		}
+	})
+
+	return categories[:length], info, nil
 }
 
 // ClearCategories ...
@@ -35,19 +402,240 @@ func ClearCategories(userId string) ([]Category, error) {
 	return applyToCategories(clear, userId)
 }
 
-func applyToCategory(name string, op operation, userId string) (*Category, error) {
+// checkCategories makes sure the categories exist, are not in the trash and
+// are for transactions of type t, an empty t takes categories of any kind
+func checkCategories(
+	uow *orm.UnitOfWork,
+	names []string,
+	t constant.TransactionType,
+	userId string,
+) error {
+	checked := make(map[string]bool)
+	for _, name := range names {
+		if checked[name] {
+			continue
+		}
+		checked[name] = true
+
+		c, err := applyToCategory(uow, name, one, userId)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("category %s does not exist", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if t != "" && !kindTakes(c.Kind, t) {
+			return fmt.Errorf(
+				"category %s is for %s, not %s",
+				name,
+				strings.ToLower(string(c.Kind)),
+				strings.ToLower(string(t)),
+			)
+		}
+	}
+	return nil
+}
+
+// withSubcategories returns the categories along with every category below
+// them, those in the trash included as transactions may still be filed under
+// them
+func withSubcategories(
+	names []string,
+	userId string,
+) (pq.StringArray, error) {
+	tree := _CategoryTree{
+		Names:    pq.StringArray(names),
+		MaxDepth: maxCategoryDepth,
+		UserId:   userId,
+	}
+	mapper := orm.NewCategoryMapper(nil, _CategoryNode{})
+
+	tmp, err := mapper.Descendants(&tree)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	categories := pq.StringArray{}
+	for _, node := range *(tmp.(*[]_CategoryNode)) {
+		if seen[node.Name] {
+			continue
+		}
+		seen[node.Name] = true
+		categories = append(categories, node.Name)
+	}
+
+	return categories, nil
+}
+
+// growCategories fills in the categories below each of the categories, a
+// category in the trash is left out along with whatever is below it
+func growCategories(categories []Category, userId string) error {
+	if len(categories) == 0 {
+		return nil
+	}
+
+	tree := _CategoryTree{
+		Names:    make(pq.StringArray, len(categories)),
+		MaxDepth: maxCategoryDepth,
+		UserId:   userId,
+	}
+	for i, category := range categories {
+		tree.Names[i] = category.Name
+	}
+	mapper := orm.NewCategoryMapper(nil, _CategoryNode{})
+
+	tmp, err := mapper.Descendants(&tree)
+	if err != nil {
+		return err
+	}
+
+	children := make(map[string][]Category)
+	for _, node := range *(tmp.(*[]_CategoryNode)) {
+		if node.Depth == 1 || node.DeletedAt != nil || node.Parent == nil {
+			continue
+		}
+		children[*node.Parent] = append(children[*node.Parent], Category{
+			Name:      node.Name,
+			Kind:      node.Kind,
+			Parent:    node.Parent,
+			CreatedAt: node.CreatedAt,
+			UserId:    node.UserId,
+		})
+	}
+
+	for i := range categories {
+		categories[i].grow(children, 1)
+	}
+	return nil
+}
+
+func (category *Category) grow(children map[string][]Category, depth int) {
+	if depth >= maxCategoryDepth {
+		return
+	}
+	category.Children = children[category.Name]
+	for i := range category.Children {
+		category.Children[i].grow(children, depth+1)
+	}
+}
+
+// checkParent makes sure category name can be filed under parent without the
+// tree growing deeper than maxCategoryDepth
+func checkParent(
+	uow *orm.UnitOfWork,
+	name string,
+	parent string,
+	userId string,
+) error {
+	depth, height, err := placeCategory(uow, name, parent, userId)
+	if err != nil {
+		return err
+	}
+	if depth+height > maxCategoryDepth {
+		return fmt.Errorf(
+			"categories may be at most %d levels deep",
+			maxCategoryDepth,
+		)
+	}
+	return nil
+}
+
+// placeCategory returns how many levels deep parent is and how many levels
+// the tree under category name has, with both locked so nothing changes
+// until the end of uow. It makes sure parent exists and is neither name nor
+// below it.
+func placeCategory(
+	uow *orm.UnitOfWork,
+	name string,
+	parent string,
+	userId string,
+) (int, int, error) {
+	if parent == name {
+		return 0, 0, errors.New("a category cannot be filed under itself")
+	}
+
+	tree := _CategoryTree{
+		Names:    pq.StringArray{name, parent},
+		MaxDepth: maxCategoryDepth,
+		UserId:   userId,
+	}
+	mapper := orm.NewCategoryMapper(uow, _CategoryNode{})
+
+	if _, err := mapper.Lock(&tree); err != nil {
+		return 0, 0, err
+	}
+	if err := checkCategories(uow, []string{parent}, "", userId); err != nil {
+		return 0, 0, err
+	}
+
+	tree.Names = pq.StringArray{parent}
+	tmp, err := mapper.Ancestors(&tree)
+	if err != nil {
+		return 0, 0, err
+	}
+	ancestors := *(tmp.(*[]_CategoryNode))
+	for _, ancestor := range ancestors {
+		if ancestor.Name == name {
+			return 0, 0, fmt.Errorf("%s is below %s", parent, name)
+		}
+	}
+
+	tree.Names = pq.StringArray{name}
+	tmp, err = mapper.Descendants(&tree)
+	if err != nil {
+		return 0, 0, err
+	}
+	height := 1
+	for _, descendant := range *(tmp.(*[]_CategoryNode)) {
+		if descendant.Depth > height {
+			height = descendant.Depth
+		}
+	}
+
+	return len(ancestors), height, nil
+}
+
+// moveCategory points everything filed under a category to another one and
+// removes the category it was moved off
+func moveCategory(
+	uow *orm.UnitOfWork,
+	_move _CategoryMove,
+) (*CategoryMove, error) {
+	mapper := orm.NewCategoryMapper(uow, CategoryMove{})
+
+	tmp, err := mapper.Move(&_move)
+	if err != nil {
+		return nil, err
+	}
+
+	dropper := orm.NewCategoryMapper(uow, Category{})
+	if _, err := dropper.Drop(&_move); err != nil {
+		return nil, err
+	}
+
+	return tmp.(*CategoryMove), nil
+}
+
+func applyToCategory(
+	uow *orm.UnitOfWork,
+	name string,
+	op operation,
+	userId string,
+) (*Category, error) {
 	c := Category{Name: name, UserId: userId}
-	mapper := orm.NewCategoryMapper(c)
+	mapper := orm.NewCategoryMapper(uow, c)
 
 	var tmp interface{}
 	var err error
 	switch op {
-	case insert:
-		tmp, err = mapper.Insert(&c)
 	case delete:
 		tmp, err = mapper.Delete(&c)
 	case one:
 		tmp, err = mapper.One(&c)
+	case restore:
+		tmp, err = mapper.Restore(&c)
 	}
 
 	if err != nil {
@@ -59,13 +647,11 @@ func applyToCategory(name string, op operation, userId string) (*Category, error
 
 func applyToCategories(op operation, userId string) ([]Category, error) {
 	category := Category{UserId: userId}
-	mapper := orm.NewCategoryMapper(category)
+	mapper := orm.NewCategoryMapper(nil, category)
 
 	var tmp interface{}
 	var err error
 	switch op {
-	case list:
-		tmp, err = mapper.Many(&category)
 	case clear:
 		tmp, err = mapper.Clear()
 	}
@@ -77,3 +663,18 @@ func applyToCategories(op operation, userId string) ([]Category, error) {
 	categories := *(tmp.(*[]Category))
 	return categories, nil
 }
+
+// kindTakes reports whether a transaction of type t may be filed under a
+// category of kind
+func kindTakes(kind constant.CategoryKind, t constant.TransactionType) bool {
+	return kind == constant.CategoryKinds().Any || string(kind) == string(t)
+}
+
+func isCategoryKind(kind constant.CategoryKind) bool {
+	for _, categoryKind := range constant.ListCategoryKinds() {
+		if string(kind) == categoryKind {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,9 @@
 package model
 
+import (
+	"github.com/expenseledger/web-service/orm"
+)
+
 type operation int
 
 const (
@@ -8,4 +12,22 @@ const (
 	one
 	list
 	clear
+	lock
+	restore
 )
+
+// inUnitOfWork runs fn in a unit of work which is committed if fn succeeds
+// and rolled back otherwise
+func inUnitOfWork(fn func(uow *orm.UnitOfWork) error) error {
+	uow, err := orm.NewUnitOfWork()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(uow); err != nil {
+		uow.Rollback()
+		return err
+	}
+
+	return uow.Commit()
+}
@@ -0,0 +1,193 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/shopspring/decimal"
+)
+
+// CreditStatus the structure is what is owed on a credit wallet and what is
+// left to spend on it. The statement fields are there only once the wallet
+// has a statement closing day, transfers into the wallet count as payments.
+type CreditStatus struct {
+	Wallet           string           `json:"wallet"`
+	Currency         string           `json:"currency"`
+	CreditLimit      *decimal.Decimal `json:"credit_limit"`
+	Outstanding      decimal.Decimal  `json:"outstanding"`
+	AvailableCredit  *decimal.Decimal `json:"available_credit"`
+	OverLimit        bool             `json:"over_limit"`
+	StatementDate    *date.Date       `json:"statement_date"`
+	DueDate          *date.Date       `json:"due_date"`
+	StatementBalance *decimal.Decimal `json:"statement_balance"`
+	Paid             *decimal.Decimal `json:"paid"`
+	StatementDue     *decimal.Decimal `json:"statement_due"`
+}
+
+// pass this to ORM when setting the terms of a credit wallet
+type _CreditTerms struct {
+	Name         string           `db:"name"`
+	CreditLimit  *decimal.Decimal `db:"credit_limit"`
+	StatementDay *int             `db:"statement_day"`
+	DueDay       *int             `db:"due_day"`
+	UserId       string           `db:"user_id"`
+}
+
+// pass this to ORM when summing up the payments made to a credit wallet
+type _CreditPayments struct {
+	Name     string          `db:"name"`
+	FromDate time.Time       `db:"from_date"`
+	ToDate   time.Time       `db:"to_date"`
+	Payments decimal.Decimal `db:"payments"`
+	UserId   string          `db:"user_id"`
+}
+
+// SetCreditTerms sets the credit limit, the statement closing day and the
+// payment due day of a credit wallet, a nil one removes it. A limit below
+// what is owed already stops any further spending until enough is paid. A
+// day past the end of a month stands for its last day.
+func SetCreditTerms(
+	name string,
+	limit *decimal.Decimal,
+	statementDay *int,
+	dueDay *int,
+	userId string,
+) (*Wallet, error) {
+	if limit != nil && limit.Sign() < 0 {
+		return nil, errors.New("credit limit must not be negative")
+	}
+	for _, day := range []*int{statementDay, dueDay} {
+		if day != nil && (*day < 1 || *day > 31) {
+			return nil, errors.New("a day of month must be between 1 and 31")
+		}
+	}
+
+	var wallet *Wallet
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		w, err := applyToWallet(uow, name, one, userId)
+		if err != nil {
+			return err
+		}
+		if w.Type != constant.WalletTypes().Credit {
+			return errors.New("only a credit wallet has credit terms")
+		}
+
+		terms := _CreditTerms{
+			Name:         name,
+			CreditLimit:  limit,
+			StatementDay: statementDay,
+			DueDay:       dueDay,
+			UserId:       userId,
+		}
+		mapper := orm.NewWalletMapper(uow, Wallet{})
+
+		tmp, err := mapper.SetCredit(&terms)
+		if err != nil {
+			return err
+		}
+
+		wallet = tmp.(*Wallet)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+// GetCreditStatus returns what is owed on a credit wallet today, along with
+// its last statement, the one closed on or before today, and what is left to
+// pay of it by its due date
+func GetCreditStatus(name string, userId string) (*CreditStatus, error) {
+	wallet, err := applyToWallet(nil, name, one, userId)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.Type != constant.WalletTypes().Credit {
+		return nil, errors.New("only a credit wallet has a credit status")
+	}
+
+	status := CreditStatus{
+		Wallet:      wallet.Name,
+		Currency:    wallet.Currency,
+		CreditLimit: wallet.CreditLimit,
+		Outstanding: owed(wallet.Balance),
+	}
+	if limit := wallet.CreditLimit; limit != nil {
+		available := decimal.Max(limit.Sub(status.Outstanding), decimal.Zero)
+		status.AvailableCredit = &available
+		status.OverLimit = status.Outstanding.GreaterThan(*limit)
+	}
+
+	if wallet.StatementDay == nil {
+		return &status, nil
+	}
+
+	today := toDay(time.Now())
+	closing := statementClosing(today, *wallet.StatementDay)
+
+	balances, err := balancesAt(name, []time.Time{closing}, userId)
+	if err != nil {
+		return nil, err
+	}
+	statement := decimal.Zero
+	if len(balances) > 0 {
+		statement = owed(balances[0].Balance)
+	}
+
+	filter := _CreditPayments{
+		Name:     name,
+		FromDate: closing.AddDate(0, 0, 1),
+		ToDate:   today.AddDate(0, 0, 1),
+		UserId:   userId,
+	}
+	mapper := orm.NewWalletMapper(nil, _CreditPayments{})
+
+	tmp, err := mapper.Payments(&filter)
+	if err != nil {
+		return nil, err
+	}
+	paid := tmp.(*_CreditPayments).Payments
+	due := decimal.Max(statement.Sub(paid), decimal.Zero)
+
+	statementDate := date.Date(closing)
+	status.StatementDate = &statementDate
+	status.StatementBalance = &statement
+	status.Paid = &paid
+	status.StatementDue = &due
+
+	if wallet.DueDay != nil {
+		dueDate := date.Date(paymentDue(closing, *wallet.DueDay))
+		status.DueDate = &dueDate
+	}
+
+	return &status, nil
+}
+
+// owed returns what a credit wallet with balance owes
+func owed(balance decimal.Decimal) decimal.Decimal {
+	return decimal.Max(balance.Neg(), decimal.Zero)
+}
+
+// statementClosing returns the last statement closing day on or before day
+func statementClosing(day time.Time, statementDay int) time.Time {
+	closing := dayOfMonth(day.Year(), day.Month(), statementDay)
+	if closing.After(day) {
+		closing = dayOfMonth(day.Year(), day.Month()-1, statementDay)
+	}
+	return closing
+}
+
+// paymentDue returns the first payment due day after the statement closing
+// day
+func paymentDue(closing time.Time, dueDay int) time.Time {
+	due := dayOfMonth(closing.Year(), closing.Month(), dueDay)
+	if !due.After(closing) {
+		due = dayOfMonth(closing.Year(), closing.Month()+1, dueDay)
+	}
+	return due
+}
@@ -0,0 +1,584 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/expenseledger/web-service/config"
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// maxEnvelopeMonths is the most months an envelope ledger may span
+const maxEnvelopeMonths = 36
+
+// Envelope the structure is a pot of money for spending on some categories,
+// and on the categories below them. Money is assigned to it month by month,
+// expenses from wallets in its currency draw it down from StartMonth on, and
+// whatever is left, or overspent, rolls into the next month.
+type Envelope struct {
+	ID         string         `json:"id" db:"id"`
+	Name       string         `json:"name" db:"name"`
+	Categories pq.StringArray `json:"categories" db:"categories"`
+	Currency   string         `json:"currency" db:"currency"`
+	StartMonth date.Date      `json:"start_month" db:"start_month"`
+	CreatedAt  time.Time      `json:"-" db:"created_at"`
+	UserId     string         `json:"userId" db:"user_id"`
+}
+
+// EnvelopeEntry the structure is money assigned to an envelope for a month,
+// or moved into or out of it, a negative amount takes money out
+type EnvelopeEntry struct {
+	ID         string                     `json:"id" db:"id"`
+	EnvelopeID string                     `json:"envelope_id" db:"envelope_id"`
+	Month      date.Date                  `json:"month" db:"month"`
+	Kind       constant.EnvelopeEntryKind `json:"kind" db:"kind"`
+	Amount     decimal.Decimal            `json:"amount" db:"amount"`
+	Note       string                     `json:"note" db:"note"`
+	CreatedAt  time.Time                  `json:"created_at" db:"created_at"`
+	UserId     string                     `json:"userId" db:"user_id"`
+}
+
+// EnvelopeMonth the structure is what happened to an envelope in a month,
+// Available is what it carries into the next one
+type EnvelopeMonth struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Envelope   string          `json:"envelope"`
+	Currency   string          `json:"currency"`
+	Month      date.Date       `json:"month"`
+	CarriedIn  decimal.Decimal `json:"carried_in"`
+	Assigned   decimal.Decimal `json:"assigned"`
+	Moved      decimal.Decimal `json:"moved"`
+	Spent      decimal.Decimal `json:"spent"`
+	Available  decimal.Decimal `json:"available"`
+}
+
+// UnassignedIncome the structure is the income in one currency in a month
+// and the money assigned to envelopes in it, ToBeAssigned is the income not
+// assigned yet since the first envelope in the currency started
+type UnassignedIncome struct {
+	Currency     string          `json:"currency"`
+	Month        date.Date       `json:"month"`
+	Income       decimal.Decimal `json:"income"`
+	Assigned     decimal.Decimal `json:"assigned"`
+	ToBeAssigned decimal.Decimal `json:"to_be_assigned"`
+}
+
+// EnvelopeLedger the structure is the envelopes of a user month by month
+type EnvelopeLedger struct {
+	Months     []EnvelopeMonth    `json:"months"`
+	Unassigned []UnassignedIncome `json:"unassigned"`
+}
+
+// pass this to ORM
+type _EnvelopeActivity struct {
+	EnvelopeID string          `db:"envelope_id"`
+	Month      time.Time       `db:"month"`
+	Assigned   decimal.Decimal `db:"assigned"`
+	Moved      decimal.Decimal `db:"moved"`
+	Spent      decimal.Decimal `db:"spent"`
+}
+
+// pass this to ORM
+type _EnvelopeIncome struct {
+	Currency string          `db:"currency"`
+	Month    time.Time       `db:"month"`
+	Income   decimal.Decimal `db:"income"`
+}
+
+// pass this to ORM when summing up envelopes
+type _EnvelopeRange struct {
+	FromDate time.Time `db:"from_date"`
+	ToDate   time.Time `db:"to_date"`
+	MaxDepth int       `db:"max_depth"`
+	UserId   string    `db:"user_id"`
+}
+
+// CreateEnvelope inserts envelope to DB, an empty currency stands for the
+// default currency and a zero start month for the current month
+func CreateEnvelope(envelope Envelope, userId string) (*Envelope, error) {
+	envelope.UserId = userId
+	if err := envelope.check(); err != nil {
+		return nil, err
+	}
+
+	if envelope.Currency == "" {
+		envelope.Currency = config.GetConfigs().DefaultCurrency
+	}
+	if !currencyCode.MatchString(envelope.Currency) {
+		return nil, errors.New("currency must be an ISO 4217 code")
+	}
+	envelope.StartMonth = date.Date(toMonthOf(envelope.StartMonth))
+
+	mapper := orm.NewEnvelopeMapper(nil, envelope)
+
+	tmp, err := mapper.Insert(&envelope)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, errors.New("envelope already exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Envelope), nil
+}
+
+// GetEnvelope returns matching envelope from DB
+func GetEnvelope(id string, userId string) (*Envelope, error) {
+	return applyToEnvelope(id, one, userId)
+}
+
+// UpdateEnvelope renames envelope and changes its categories, its currency
+// and its start month stay as they are
+func UpdateEnvelope(envelope Envelope, userId string) (*Envelope, error) {
+	envelope.UserId = userId
+	if err := envelope.check(); err != nil {
+		return nil, err
+	}
+
+	mapper := orm.NewEnvelopeMapper(nil, envelope)
+
+	tmp, err := mapper.Update(&envelope)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, errors.New("envelope already exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Envelope), nil
+}
+
+// DeleteEnvelope removes envelope from DB along with the money assigned to
+// it and moved into or out of it
+func DeleteEnvelope(id string, userId string) (*Envelope, error) {
+	return applyToEnvelope(id, delete, userId)
+}
+
+// ListEnvelopes returns a page of the envelopes of the user
+func ListEnvelopes(page Page, userId string) ([]Envelope, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewEnvelopeMapper(nil, Envelope{})
+
+	tmp, err := mapper.Many(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envelopes := *(tmp.(*[]Envelope))
+	length, info := page.cut(len(envelopes), func(i int) cursor {
+		return cursor{CreatedAt: envelopes[i].CreatedAt, Key: envelopes[i].ID}
+	})
+
+	return envelopes[:length], info, nil
+}
+
+// AssignToEnvelope assigns income to an envelope for the month a day falls
+// in, the current month when the day is zero. A negative amount takes money
+// back out of the envelope.
+func AssignToEnvelope(
+	id string,
+	month date.Date,
+	amount decimal.Decimal,
+	note string,
+	userId string,
+) (*EnvelopeEntry, error) {
+	if amount.IsZero() {
+		return nil, errors.New("amount must not be zero")
+	}
+
+	var entry *EnvelopeEntry
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		envelope, err := envelopeOf(uow, id, userId)
+		if err != nil {
+			return err
+		}
+
+		entry, err = envelope.insertEntry(
+			uow,
+			month,
+			constant.EnvelopeEntryKinds().Assign,
+			amount,
+			note,
+		)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// MoveBetweenEnvelopes moves money from one envelope to another of the same
+// currency for the month a day falls in, the current month when the day is
+// zero, both entries are made in one unit of work
+func MoveBetweenEnvelopes(
+	fromID string,
+	toID string,
+	month date.Date,
+	amount decimal.Decimal,
+	note string,
+	userId string,
+) ([]EnvelopeEntry, error) {
+	if fromID == toID {
+		return nil, errors.New("money cannot be moved within one envelope")
+	}
+	if amount.Sign() <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	var entries []EnvelopeEntry
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		from, err := envelopeOf(uow, fromID, userId)
+		if err != nil {
+			return err
+		}
+		to, err := envelopeOf(uow, toID, userId)
+		if err != nil {
+			return err
+		}
+		if from.Currency != to.Currency {
+			return fmt.Errorf(
+				"money cannot be moved from %s in %s to %s in %s",
+				from.Name,
+				from.Currency,
+				to.Name,
+				to.Currency,
+			)
+		}
+
+		move := constant.EnvelopeEntryKinds().Move
+		out, err := from.insertEntry(uow, month, move, amount.Neg(), note)
+		if err != nil {
+			return err
+		}
+		in, err := to.insertEntry(uow, month, move, amount, note)
+		if err != nil {
+			return err
+		}
+
+		entries = []EnvelopeEntry{*out, *in}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetEnvelopeLedger returns each envelope of the user month by month between
+// the months the days fall in, both inclusive, along with the income left to
+// assign in each currency. A zero day stands for the current month.
+func GetEnvelopeLedger(
+	fromMonth date.Date,
+	toMonth date.Date,
+	userId string,
+) (*EnvelopeLedger, error) {
+	from, to := toMonthOf(fromMonth), toMonthOf(toMonth)
+	if to.Before(from) {
+		return nil, errors.New("to month must not be before from month")
+	}
+	if to.After(from.AddDate(0, maxEnvelopeMonths-1, 0)) {
+		return nil, fmt.Errorf(
+			"an envelope ledger may span at most %d months",
+			maxEnvelopeMonths,
+		)
+	}
+
+	ledger := EnvelopeLedger{
+		Months:     []EnvelopeMonth{},
+		Unassigned: []UnassignedIncome{},
+	}
+
+	mapper := orm.NewEnvelopeMapper(nil, Envelope{})
+	tmp, err := mapper.All(&Envelope{UserId: userId})
+	if err != nil {
+		return nil, err
+	}
+	envelopes := *(tmp.(*[]Envelope))
+	if len(envelopes) == 0 {
+		return &ledger, nil
+	}
+
+	end := to.AddDate(0, 1, 0)
+	activities, err := envelopeActivities(end, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// each envelope starts with nothing in its start month and carries what
+	// is available from month to month
+	starts := make(map[string]time.Time)
+	assigned := make(map[string]map[time.Time]decimal.Decimal)
+	for _, envelope := range envelopes {
+		start := toDay(time.Time(envelope.StartMonth))
+		if s, ok := starts[envelope.Currency]; !ok || start.Before(s) {
+			starts[envelope.Currency] = start
+		}
+		if assigned[envelope.Currency] == nil {
+			assigned[envelope.Currency] = make(map[time.Time]decimal.Decimal)
+		}
+
+		available := decimal.Zero
+		for month := start; month.Before(end); month = month.AddDate(0, 1, 0) {
+			activity := activities[envelope.ID][month]
+			m := EnvelopeMonth{
+				EnvelopeID: envelope.ID,
+				Envelope:   envelope.Name,
+				Currency:   envelope.Currency,
+				Month:      date.Date(month),
+				CarriedIn:  available,
+				Assigned:   activity.Assigned,
+				Moved:      activity.Moved,
+				Spent:      activity.Spent,
+			}
+			m.Available = available.Add(m.Assigned).Add(m.Moved).Sub(m.Spent)
+			available = m.Available
+
+			byMonth := assigned[envelope.Currency]
+			byMonth[month] = byMonth[month].Add(m.Assigned)
+
+			if !month.Before(from) {
+				ledger.Months = append(ledger.Months, m)
+			}
+		}
+	}
+
+	earliest := end
+	for _, start := range starts {
+		if start.Before(earliest) {
+			earliest = start
+		}
+	}
+	incomes, err := envelopeIncomes(earliest, end, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make([]string, 0, len(starts))
+	for currency := range starts {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		toBeAssigned := decimal.Zero
+		start := starts[currency]
+		for month := start; month.Before(end); month = month.AddDate(0, 1, 0) {
+			u := UnassignedIncome{
+				Currency: currency,
+				Month:    date.Date(month),
+				Income:   incomes[currency][month],
+				Assigned: assigned[currency][month],
+			}
+			toBeAssigned = toBeAssigned.Add(u.Income).Sub(u.Assigned)
+			u.ToBeAssigned = toBeAssigned
+
+			if !month.Before(from) {
+				ledger.Unassigned = append(ledger.Unassigned, u)
+			}
+		}
+	}
+
+	return &ledger, nil
+}
+
+func envelopeActivities(
+	end time.Time,
+	userId string,
+) (map[string]map[time.Time]_EnvelopeActivity, error) {
+	filter := _EnvelopeRange{
+		ToDate:   end,
+		MaxDepth: maxCategoryDepth,
+		UserId:   userId,
+	}
+	mapper := orm.NewEnvelopeMapper(nil, _EnvelopeActivity{})
+
+	tmp, err := mapper.Activity(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	activities := make(map[string]map[time.Time]_EnvelopeActivity)
+	for _, activity := range *(tmp.(*[]_EnvelopeActivity)) {
+		if activities[activity.EnvelopeID] == nil {
+			activities[activity.EnvelopeID] =
+				make(map[time.Time]_EnvelopeActivity)
+		}
+		activities[activity.EnvelopeID][toDay(activity.Month)] = activity
+	}
+
+	return activities, nil
+}
+
+func envelopeIncomes(
+	start time.Time,
+	end time.Time,
+	userId string,
+) (map[string]map[time.Time]decimal.Decimal, error) {
+	filter := _EnvelopeRange{FromDate: start, ToDate: end, UserId: userId}
+	mapper := orm.NewEnvelopeMapper(nil, _EnvelopeIncome{})
+
+	tmp, err := mapper.Income(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	incomes := make(map[string]map[time.Time]decimal.Decimal)
+	for _, income := range *(tmp.(*[]_EnvelopeIncome)) {
+		if incomes[income.Currency] == nil {
+			incomes[income.Currency] = make(map[time.Time]decimal.Decimal)
+		}
+		incomes[income.Currency][toDay(income.Month)] = income.Income
+	}
+
+	return incomes, nil
+}
+
+// envelopeOf returns an envelope as part of uow
+func envelopeOf(
+	uow *orm.UnitOfWork,
+	id string,
+	userId string,
+) (*Envelope, error) {
+	envelope := Envelope{ID: id, UserId: userId}
+	mapper := orm.NewEnvelopeMapper(uow, envelope)
+
+	tmp, err := mapper.One(&envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Envelope), nil
+}
+
+func (envelope *Envelope) insertEntry(
+	uow *orm.UnitOfWork,
+	month date.Date,
+	kind constant.EnvelopeEntryKind,
+	amount decimal.Decimal,
+	note string,
+) (*EnvelopeEntry, error) {
+	m := toMonthOf(month)
+	if m.Before(time.Time(envelope.StartMonth)) {
+		return nil, fmt.Errorf(
+			"%s starts on %s",
+			envelope.Name,
+			time.Time(envelope.StartMonth).Format("2006-01"),
+		)
+	}
+
+	entry := EnvelopeEntry{
+		EnvelopeID: envelope.ID,
+		Month:      date.Date(m),
+		Kind:       kind,
+		Amount:     amount,
+		Note:       note,
+		UserId:     envelope.UserId,
+	}
+	mapper := orm.NewEnvelopeMapper(uow, entry)
+
+	tmp, err := mapper.InsertEntry(&entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*EnvelopeEntry), nil
+}
+
+// check validates the name and the categories of the envelope, a category
+// can only be in one envelope
+func (envelope *Envelope) check() error {
+	if envelope.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(envelope.Categories) == 0 {
+		return errors.New("an envelope needs at least one category")
+	}
+
+	seen := make(map[string]bool)
+	categories := pq.StringArray{}
+	for _, category := range envelope.Categories {
+		if seen[category] {
+			continue
+		}
+		seen[category] = true
+		categories = append(categories, category)
+	}
+	envelope.Categories = categories
+
+	err := checkCategories(
+		nil,
+		envelope.Categories,
+		constant.TransactionTypes().Expense,
+		envelope.UserId,
+	)
+	if err != nil {
+		return err
+	}
+
+	mapper := orm.NewEnvelopeMapper(nil, Envelope{})
+
+	tmp, err := mapper.Overlap(envelope)
+	if err != nil {
+		return err
+	}
+	for _, other := range *(tmp.(*[]Envelope)) {
+		for _, category := range other.Categories {
+			if seen[category] {
+				return fmt.Errorf(
+					"category %s is in envelope %s already",
+					category,
+					other.Name,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// toMonthOf returns the first day of the month a day falls in, of the
+// current month when the day is zero
+func toMonthOf(day date.Date) time.Time {
+	t := time.Time(day)
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func applyToEnvelope(
+	id string,
+	op operation,
+	userId string,
+) (*Envelope, error) {
+	envelope := Envelope{ID: id, UserId: userId}
+	mapper := orm.NewEnvelopeMapper(nil, envelope)
+
+	var tmp interface{}
+	var err error
+	switch op {
+	case delete:
+		tmp, err = mapper.Delete(&envelope)
+	case one:
+		tmp, err = mapper.One(&envelope)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Envelope), nil
+}
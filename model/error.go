@@ -0,0 +1,14 @@
+package model
+
+import "github.com/expenseledger/web-service/constant"
+
+// CodedError the structure is an error which comes with a code, so the app
+// can tell it apart without reading its message
+type CodedError struct {
+	Code    constant.ErrorCode
+	Message string
+}
+
+func (err *CodedError) Error() string {
+	return err.Message
+}
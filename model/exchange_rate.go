@@ -0,0 +1,446 @@
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/expenseledger/web-service/config"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate the structure tells how much one unit of From is worth in To
+// from Date on, until the next rate of the same currencies takes effect
+type ExchangeRate struct {
+	From      string          `json:"from" db:"src_currency"`
+	To        string          `json:"to" db:"dst_currency"`
+	Date      date.Date       `json:"date" db:"effective_on"`
+	Rate      decimal.Decimal `json:"rate" db:"rate"`
+	CreatedAt time.Time       `json:"-" db:"created_at"`
+	UserId    string          `json:"userId" db:"user_id"`
+}
+
+// pass this to ORM when listing exchange rates
+type _ExchangeRatePage struct {
+	From   string `db:"src_currency"`
+	To     string `db:"dst_currency"`
+	UserId string `db:"user_id"`
+	orm.Page
+}
+
+// SetExchangeRate inserts an exchange rate, or replaces the rate of the same
+// currencies on the same date
+func SetExchangeRate(rate ExchangeRate, userId string) (*ExchangeRate, error) {
+	return setExchangeRate(nil, rate, userId)
+}
+
+// ImportExchangeRates sets every exchange rate of a CSV file in one unit of
+// work, either all of them are set or none. Each record holds the from and
+// to currencies, the date and the rate, in this order. The first record may
+// be a header starting with "from".
+func ImportExchangeRates(r io.Reader, userId string) ([]ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && strings.EqualFold(records[0][0], "from") {
+		records = records[1:]
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no exchange rate to import")
+	}
+
+	rates := make([]ExchangeRate, 0, len(records))
+	err = inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		for i, record := range records {
+			rate, err := parseExchangeRate(record)
+			if err == nil {
+				var tmp *ExchangeRate
+				tmp, err = setExchangeRate(uow, *rate, userId)
+				rate = tmp
+			}
+			if err != nil {
+				return fmt.Errorf("exchange rate #%d: %s", i+1, err)
+			}
+			rates = append(rates, *rate)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// GetExchangeRate returns the exchange rate set for the currencies on date
+func GetExchangeRate(
+	from string,
+	to string,
+	d date.Date,
+	userId string,
+) (*ExchangeRate, error) {
+	return applyToExchangeRate(from, to, d, one, userId)
+}
+
+// DeleteExchangeRate removes the exchange rate set for the currencies on date
+func DeleteExchangeRate(
+	from string,
+	to string,
+	d date.Date,
+	userId string,
+) (*ExchangeRate, error) {
+	return applyToExchangeRate(from, to, d, delete, userId)
+}
+
+// ListExchangeRates returns a page of the exchange rates of the user, those
+// from and to the given currencies when they are not empty
+func ListExchangeRates(
+	from string,
+	to string,
+	page Page,
+	userId string,
+) ([]ExchangeRate, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewExchangeRateMapper(nil, ExchangeRate{})
+
+	tmp, err := mapper.Many(&_ExchangeRatePage{
+		From:   from,
+		To:     to,
+		UserId: userId,
+		Page:   *_page,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rates := *(tmp.(*[]ExchangeRate))
+	length, info := page.cut(len(rates), func(i int) cursor {
+		return cursor{CreatedAt: rates[i].CreatedAt, Key: rates[i].key()}
+	})
+
+	return rates[:length], info, nil
+}
+
+// Convert expresses an amount in currency on a date in another currency, an
+// empty currency to convert to stands for the default currency
+func Convert(
+	amount decimal.Decimal,
+	from string,
+	to string,
+	on date.Date,
+	userId string,
+) (decimal.Decimal, error) {
+	converter, err := NewConverter(nil, to, userId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	tim := time.Time(on)
+	if tim.IsZero() {
+		tim = time.Now()
+	}
+
+	return converter.Convert(amount, from, tim)
+}
+
+// ConvertTransactions sets the base amount of the transactions, their amount
+// in currency on the date they occurred at
+func ConvertTransactions(
+	txs []Transaction,
+	currency string,
+	userId string,
+) error {
+	converter, err := NewConverter(nil, currency, userId)
+	if err != nil {
+		return err
+	}
+
+	for i := range txs {
+		tx := &txs[i]
+
+		wallet := tx.From
+		if wallet == "" {
+			wallet = tx.To
+		}
+		from, err := converter.currencyOf(wallet)
+		if err != nil {
+			return err
+		}
+
+		amount, err := converter.Convert(tx.Amount, from, tx.OccurredAt)
+		if err != nil {
+			return err
+		}
+		tx.BaseAmount = &amount
+	}
+
+	return nil
+}
+
+// ConvertWallets sets the base balance of the wallets, their balance in
+// currency at the rates effective today
+func ConvertWallets(wallets []Wallet, currency string, userId string) error {
+	converter, err := NewConverter(nil, currency, userId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range wallets {
+		wallet := &wallets[i]
+
+		balance, err := converter.Convert(wallet.Balance, wallet.Currency, now)
+		if err != nil {
+			return err
+		}
+		wallet.BaseBalance = &balance
+	}
+
+	return nil
+}
+
+// Converter expresses amounts in one currency with the exchange rates of a
+// user. An amount is converted at the latest rate effective on its date, a
+// rate set only the other way round is used inverted.
+type Converter struct {
+	Currency   string
+	uow        *orm.UnitOfWork
+	userId     string
+	histories  map[string][]ratePoint
+	currencies map[string]string
+}
+
+// ratePoint the structure is the rate into the currency of a converter which
+// takes effect on a date, On is formatted as 2006-01-02
+type ratePoint struct {
+	On   string
+	Rate decimal.Decimal
+}
+
+// NewConverter returns a converter into currency as part of uow, an empty
+// currency stands for the default currency
+func NewConverter(
+	uow *orm.UnitOfWork,
+	currency string,
+	userId string,
+) (*Converter, error) {
+	if currency == "" {
+		currency = config.GetConfigs().DefaultCurrency
+	}
+	if !currencyCode.MatchString(currency) {
+		return nil, errors.New("currency must be an ISO 4217 code")
+	}
+
+	return &Converter{
+		Currency:   currency,
+		uow:        uow,
+		userId:     userId,
+		histories:  make(map[string][]ratePoint),
+		currencies: make(map[string]string),
+	}, nil
+}
+
+// Convert expresses an amount in currency on a date in the currency of the
+// converter, rounded to cents
+func (converter *Converter) Convert(
+	amount decimal.Decimal,
+	currency string,
+	on time.Time,
+) (decimal.Decimal, error) {
+	if currency == converter.Currency {
+		return amount, nil
+	}
+
+	history, err := converter.historyOf(currency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	day := on.Format("2006-01-02")
+	i := sort.Search(len(history), func(i int) bool {
+		return history[i].On > day
+	})
+	if i == 0 {
+		return decimal.Zero, fmt.Errorf(
+			"no exchange rate from %s to %s on %s",
+			currency,
+			converter.Currency,
+			day,
+		)
+	}
+
+	return amount.Mul(history[i-1].Rate).Round(2), nil
+}
+
+// historyOf returns the rates from currency into the currency of the
+// converter by the date they take effect on, a rate set for a date either
+// way round wins over the inverse of the other one
+func (converter *Converter) historyOf(currency string) ([]ratePoint, error) {
+	if history, ok := converter.histories[currency]; ok {
+		return history, nil
+	}
+
+	pair := ExchangeRate{
+		From:   currency,
+		To:     converter.Currency,
+		UserId: converter.userId,
+	}
+	mapper := orm.NewExchangeRateMapper(converter.uow, pair)
+
+	tmp, err := mapper.History(&pair)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]decimal.Decimal)
+	for _, rate := range *(tmp.(*[]ExchangeRate)) {
+		on := time.Time(rate.Date).Format("2006-01-02")
+		if rate.From == currency {
+			rates[on] = rate.Rate
+		} else if _, ok := rates[on]; !ok {
+			rates[on] = decimal.New(1, 0).DivRound(rate.Rate, 10)
+		}
+	}
+
+	history := make([]ratePoint, 0, len(rates))
+	for on, rate := range rates {
+		history = append(history, ratePoint{On: on, Rate: rate})
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].On < history[j].On
+	})
+
+	converter.histories[currency] = history
+	return history, nil
+}
+
+// currencyOf returns the currency of a wallet of the user, one in the trash
+// too since its transactions are still listed
+func (converter *Converter) currencyOf(name string) (string, error) {
+	if currency, ok := converter.currencies[name]; ok {
+		return currency, nil
+	}
+
+	wallet := Wallet{Name: name, UserId: converter.userId}
+	mapper := orm.NewWalletMapper(converter.uow, wallet)
+
+	tmp, err := mapper.Find(&wallet)
+	if err != nil {
+		return "", err
+	}
+
+	currency := tmp.(*Wallet).Currency
+	converter.currencies[name] = currency
+	return currency, nil
+}
+
+func setExchangeRate(
+	uow *orm.UnitOfWork,
+	rate ExchangeRate,
+	userId string,
+) (*ExchangeRate, error) {
+	rate.UserId = userId
+	if err := rate.check(); err != nil {
+		return nil, err
+	}
+
+	mapper := orm.NewExchangeRateMapper(uow, rate)
+
+	tmp, err := mapper.Insert(&rate)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*ExchangeRate), nil
+}
+
+// parseExchangeRate reads an exchange rate from a CSV record
+func parseExchangeRate(record []string) (*ExchangeRate, error) {
+	on, err := time.Parse("2006-01-02", record[2])
+	if err != nil {
+		return nil, errors.New("date must be formatted as 2006-01-02")
+	}
+
+	rate, err := decimal.NewFromString(record[3])
+	if err != nil {
+		return nil, errors.New("rate must be a number")
+	}
+
+	return &ExchangeRate{
+		From: strings.ToUpper(record[0]),
+		To:   strings.ToUpper(record[1]),
+		Date: date.Date(on),
+		Rate: rate,
+	}, nil
+}
+
+// check validates the exchange rate, a rate with no date takes effect today
+func (rate *ExchangeRate) check() error {
+	if !currencyCode.MatchString(rate.From) ||
+		!currencyCode.MatchString(rate.To) {
+		return errors.New("currency must be an ISO 4217 code")
+	}
+	if rate.From == rate.To {
+		return errors.New("an exchange rate needs two different currencies")
+	}
+	if rate.Rate.Sign() <= 0 {
+		return errors.New("rate must be positive")
+	}
+	if time.Time(rate.Date).IsZero() {
+		rate.Date = date.Date(toDay(time.Now()))
+	}
+
+	return nil
+}
+
+// key identifies the exchange rate among those of the user, in the order
+// they are listed in
+func (rate *ExchangeRate) key() string {
+	return fmt.Sprintf(
+		"%s/%s/%s",
+		rate.From,
+		rate.To,
+		time.Time(rate.Date).Format("2006-01-02"),
+	)
+}
+
+func applyToExchangeRate(
+	from string,
+	to string,
+	d date.Date,
+	op operation,
+	userId string,
+) (*ExchangeRate, error) {
+	rate := ExchangeRate{From: from, To: to, Date: d, UserId: userId}
+	mapper := orm.NewExchangeRateMapper(nil, rate)
+
+	var tmp interface{}
+	var err error
+	switch op {
+	case delete:
+		tmp, err = mapper.Delete(&rate)
+	case one:
+		tmp, err = mapper.One(&rate)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*ExchangeRate), nil
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/shopspring/decimal"
+)
+
+// TestConvertTrashedWalletTransactions converts a list of transactions one
+// of which belongs to a wallet in the trash, which is still listed
+func TestConvertTrashedWalletTransactions(t *testing.T) {
+	needDB(t)
+
+	userId := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	effective := date.Date(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer func() {
+		DeleteExchangeRate("THB", "EUR", effective, userId)
+		ClearTransactions(userId)
+		ClearWallets(userId)
+		ClearCategories(userId)
+	}()
+
+	if _, err := CreateCategory(
+		"Test",
+		constant.CategoryKinds().Any,
+		"",
+		userId,
+	); err != nil {
+		t.Fatal("creating category:", err)
+	}
+	currencies := map[string]string{"Home": "EUR", "Trip": "THB"}
+	for name, currency := range currencies {
+		_, err := CreateWallet(
+			name,
+			constant.WalletTypes().Cash,
+			decimal.New(1000, 0),
+			currency,
+			userId,
+		)
+		if err != nil {
+			t.Fatal("creating wallet:", err)
+		}
+	}
+
+	_, err := SetExchangeRate(ExchangeRate{
+		From: "THB",
+		To:   "EUR",
+		Date: effective,
+		Rate: decimal.New(25, -3),
+	}, userId)
+	if err != nil {
+		t.Fatal("setting exchange rate:", err)
+	}
+
+	occurred := date.Date(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+	amounts := map[string]decimal.Decimal{
+		"Home": decimal.New(5, 0),
+		"Trip": decimal.New(400, 0),
+	}
+	for wallet, amount := range amounts {
+		_, _, err := CreateTransction(TransactionInput{
+			Amount:   amount,
+			Type:     constant.TransactionTypes().Expense,
+			From:     wallet,
+			Category: "Test",
+			Date:     occurred,
+		}, userId)
+		if err != nil {
+			t.Fatal("creating transaction:", err)
+		}
+	}
+
+	if _, err := DeleteWallet("Trip", userId); err != nil {
+		t.Fatal("deleting wallet:", err)
+	}
+
+	txs, _, err := ListTransactions(TransactionFilter{}, Page{}, userId)
+	if err != nil {
+		t.Fatal("listing transactions:", err)
+	}
+	if len(txs) != len(amounts) {
+		t.Fatalf("listed %d transactions, want %d", len(txs), len(amounts))
+	}
+
+	if err := ConvertTransactions(txs, "EUR", userId); err != nil {
+		t.Fatal("converting transactions:", err)
+	}
+
+	want := map[string]decimal.Decimal{
+		"Home": decimal.New(5, 0),
+		"Trip": decimal.New(10, 0),
+	}
+	for _, tx := range txs {
+		if tx.BaseAmount == nil || !tx.BaseAmount.Equal(want[tx.From]) {
+			t.Errorf(
+				"base amount of the expense from %s is %v, want %s",
+				tx.From,
+				tx.BaseAmount,
+				want[tx.From],
+			)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// defaultGoalMonths is how many months back the projection of a goal
+	// looks at the inflows into its wallets when not told otherwise
+	defaultGoalMonths = 3
+	// maxGoalMonths is the most months back the projection of a goal may look
+	maxGoalMonths = 24
+	// maxGoalProjectionDays is how far ahead a goal may be projected to be
+	// reached, a slower pace leaves it without a projection
+	maxGoalProjectionDays = 100 * 366
+)
+
+// Goal the structure is an amount a user saves toward, by a day or not, in
+// one or more wallets of one currency. Only a share of the balance of a
+// wallet may count for a goal, so one wallet can hold the savings of several.
+type Goal struct {
+	ID           string          `json:"id" db:"id"`
+	Name         string          `json:"name" db:"name"`
+	TargetAmount decimal.Decimal `json:"target_amount" db:"target_amount"`
+	TargetDate   *date.Date      `json:"target_date" db:"target_date"`
+	Currency     string          `json:"currency" db:"currency"`
+	Wallets      []GoalWallet    `json:"wallets" db:"-"`
+	CreatedAt    time.Time       `json:"-" db:"created_at"`
+	UserId       string          `json:"userId" db:"user_id"`
+}
+
+// GoalWallet the structure is a wallet linked to a goal, share is the
+// percentage of its balance which counts for the goal
+type GoalWallet struct {
+	GoalID string          `json:"-" db:"goal_id"`
+	Wallet string          `json:"wallet" db:"wallet"`
+	Share  decimal.Decimal `json:"share" db:"share"`
+	UserId string          `json:"-" db:"user_id"`
+}
+
+// GoalProgress the structure is how far a goal is, what it takes each month
+// to reach it by its target date and when it is reached at the pace money
+// went into its wallets lately
+type GoalProgress struct {
+	GoalID               string               `json:"goal_id"`
+	Name                 string               `json:"name"`
+	Currency             string               `json:"currency"`
+	TargetAmount         decimal.Decimal      `json:"target_amount"`
+	TargetDate           *date.Date           `json:"target_date"`
+	Saved                decimal.Decimal      `json:"saved"`
+	Remaining            decimal.Decimal      `json:"remaining"`
+	Percentage           decimal.Decimal      `json:"percentage"`
+	Reached              bool                 `json:"reached"`
+	MonthsLeft           *int                 `json:"months_left"`
+	RequiredMonthly      *decimal.Decimal     `json:"required_monthly"`
+	RecentMonths         int                  `json:"recent_months"`
+	RecentInflow         decimal.Decimal      `json:"recent_inflow"`
+	AverageMonthlyInflow decimal.Decimal      `json:"average_monthly_inflow"`
+	ProjectedDate        *date.Date           `json:"projected_date"`
+	OnTrack              *bool                `json:"on_track"`
+	Wallets              []GoalWalletProgress `json:"wallets"`
+}
+
+// GoalWalletProgress the structure is what a wallet linked to a goal holds
+// for it and the net amount which went into it lately
+type GoalWalletProgress struct {
+	Wallet    string          `json:"wallet" db:"wallet"`
+	Share     decimal.Decimal `json:"share" db:"share"`
+	Balance   decimal.Decimal `json:"balance" db:"balance"`
+	Saved     decimal.Decimal `json:"saved" db:"-"`
+	NetInflow decimal.Decimal `json:"net_inflow" db:"net_inflow"`
+}
+
+// pass this to ORM when asking for the wallets of goals
+type _GoalIDs struct {
+	IDs    pq.StringArray `db:"ids"`
+	UserId string         `db:"user_id"`
+}
+
+// pass this to ORM when asking how much of wallets other goals count
+type _GoalShareFilter struct {
+	ID      string         `db:"id"`
+	Wallets pq.StringArray `db:"wallets"`
+	UserId  string         `db:"user_id"`
+}
+
+// pass this to ORM when asking what the wallets of a goal hold
+type _GoalSavedFilter struct {
+	ID       string    `db:"id"`
+	FromDate time.Time `db:"from_date"`
+	ToDate   time.Time `db:"to_date"`
+	UserId   string    `db:"user_id"`
+}
+
+// CreateGoal inserts goal to DB along with the wallets linked to it, all in
+// one unit of work. A zero share counts the whole balance of a wallet.
+func CreateGoal(goal Goal, userId string) (*Goal, error) {
+	goal.UserId = userId
+
+	var created *Goal
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		if err := goal.check(uow); err != nil {
+			return err
+		}
+
+		mapper := orm.NewGoalMapper(uow, goal)
+
+		tmp, err := mapper.Insert(&goal)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errors.New("goal already exists")
+		}
+		if err != nil {
+			return err
+		}
+		created = tmp.(*Goal)
+
+		return created.link(uow, goal.Wallets)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// GetGoal returns matching goal from DB along with the wallets linked to it
+func GetGoal(id string, userId string) (*Goal, error) {
+	goal, err := applyToGoal(id, one, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	goals := []Goal{*goal}
+	if err := linkedWallets(goals, userId); err != nil {
+		return nil, err
+	}
+
+	return &goals[0], nil
+}
+
+// UpdateGoal rewrites the name, the target and the wallets of a goal, all in
+// one unit of work
+func UpdateGoal(goal Goal, userId string) (*Goal, error) {
+	goal.UserId = userId
+
+	var updated *Goal
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		if err := goal.check(uow); err != nil {
+			return err
+		}
+
+		mapper := orm.NewGoalMapper(uow, goal)
+
+		tmp, err := mapper.Update(&goal)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errors.New("goal already exists")
+		}
+		if err != nil {
+			return err
+		}
+		updated = tmp.(*Goal)
+
+		_, err = orm.NewGoalMapper(uow, GoalWallet{}).Unlink(updated)
+		if err != nil {
+			return err
+		}
+
+		return updated.link(uow, goal.Wallets)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// DeleteGoal removes goal from DB, the wallets linked to it are left as they
+// are
+func DeleteGoal(id string, userId string) (*Goal, error) {
+	return applyToGoal(id, delete, userId)
+}
+
+// ListGoals returns a page of the goals of the user
+func ListGoals(page Page, userId string) ([]Goal, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewGoalMapper(nil, Goal{})
+
+	tmp, err := mapper.Many(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	goals := *(tmp.(*[]Goal))
+	length, info := page.cut(len(goals), func(i int) cursor {
+		return cursor{CreatedAt: goals[i].CreatedAt, Key: goals[i].ID}
+	})
+	goals = goals[:length]
+
+	if err := linkedWallets(goals, userId); err != nil {
+		return nil, nil, err
+	}
+
+	return goals, info, nil
+}
+
+// GetGoalProgress returns how far a goal, or each goal of the user when id
+// is empty, is today. Its projection goes by the net amount which went into
+// its wallets in the last months, three when months is zero.
+func GetGoalProgress(
+	id string,
+	months int,
+	userId string,
+) ([]GoalProgress, error) {
+	if months == 0 {
+		months = defaultGoalMonths
+	}
+	if months < 0 || months > maxGoalMonths {
+		return nil, fmt.Errorf(
+			"months must be between 1 and %d",
+			maxGoalMonths,
+		)
+	}
+
+	var goals []Goal
+	if id != "" {
+		goal, err := applyToGoal(id, one, userId)
+		if err != nil {
+			return nil, err
+		}
+		goals = []Goal{*goal}
+	} else {
+		mapper := orm.NewGoalMapper(nil, Goal{})
+
+		tmp, err := mapper.All(&Goal{UserId: userId})
+		if err != nil {
+			return nil, err
+		}
+		goals = *(tmp.(*[]Goal))
+	}
+
+	today := toDay(time.Now())
+	progress := make([]GoalProgress, len(goals))
+	for i := range goals {
+		p, err := goals[i].progress(today, months)
+		if err != nil {
+			return nil, err
+		}
+		progress[i] = *p
+	}
+
+	return progress, nil
+}
+
+// progress works out how far the goal is on a day, going by the net inflow
+// into its wallets in the months before
+func (goal *Goal) progress(today time.Time, months int) (*GoalProgress, error) {
+	from, to := today.AddDate(0, -months, 0), today.AddDate(0, 0, 1)
+	filter := _GoalSavedFilter{
+		ID:       goal.ID,
+		FromDate: from,
+		ToDate:   to,
+		UserId:   goal.UserId,
+	}
+	mapper := orm.NewGoalMapper(nil, GoalWalletProgress{})
+
+	tmp, err := mapper.Saved(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := GoalProgress{
+		GoalID:       goal.ID,
+		Name:         goal.Name,
+		Currency:     goal.Currency,
+		TargetAmount: goal.TargetAmount,
+		TargetDate:   goal.TargetDate,
+		Saved:        decimal.Zero,
+		RecentMonths: months,
+		RecentInflow: decimal.Zero,
+		Wallets:      *(tmp.(*[]GoalWalletProgress)),
+	}
+
+	hundred := decimal.New(100, 0)
+	for i := range p.Wallets {
+		wallet := &p.Wallets[i]
+		share := wallet.Share.Div(hundred)
+		wallet.Saved = wallet.Balance.Mul(share).Round(2)
+		wallet.NetInflow = wallet.NetInflow.Mul(share).Round(2)
+
+		p.Saved = p.Saved.Add(wallet.Saved)
+		p.RecentInflow = p.RecentInflow.Add(wallet.NetInflow)
+	}
+
+	p.Remaining = decimal.Max(p.TargetAmount.Sub(p.Saved), decimal.Zero)
+	p.Percentage = p.Saved.Mul(hundred).Div(p.TargetAmount).Round(2)
+	p.Reached = p.Remaining.IsZero()
+	p.AverageMonthlyInflow =
+		p.RecentInflow.Div(decimal.New(int64(months), 0)).Round(2)
+
+	if p.TargetDate != nil {
+		target := time.Time(*p.TargetDate)
+		left := monthsUntil(today, target)
+		required := p.Remaining
+		if left > 0 {
+			required = p.Remaining.Div(decimal.New(int64(left), 0)).Round(2)
+		}
+		p.MonthsLeft = &left
+		p.RequiredMonthly = &required
+	}
+
+	if p.Reached {
+		projected := date.Date(today)
+		p.ProjectedDate = &projected
+	} else if p.RecentInflow.Sign() > 0 {
+		days := decimal.New(int64(to.Sub(from).Hours()/24), 0)
+		rate := p.RecentInflow.Div(days)
+		need := p.Remaining.Div(rate).Ceil()
+		if need.LessThanOrEqual(decimal.New(maxGoalProjectionDays, 0)) {
+			projected := date.Date(today.AddDate(0, 0, int(need.IntPart())))
+			p.ProjectedDate = &projected
+		}
+	}
+
+	if p.TargetDate != nil {
+		onTrack := p.ProjectedDate != nil &&
+			!time.Time(*p.ProjectedDate).After(time.Time(*p.TargetDate))
+		p.OnTrack = &onTrack
+	}
+
+	return &p, nil
+}
+
+// check validates the goal and its wallets as part of uow, the wallets must
+// share one currency, which becomes the currency of the goal, and no wallet
+// may count for goals more than its whole balance
+func (goal *Goal) check(uow *orm.UnitOfWork) error {
+	if goal.Name == "" {
+		return errors.New("name is required")
+	}
+	if goal.TargetAmount.Sign() <= 0 {
+		return errors.New("target amount must be positive")
+	}
+	if len(goal.Wallets) == 0 {
+		return errors.New("a goal needs at least one wallet")
+	}
+	if goal.TargetDate != nil {
+		target := date.Date(toDay(time.Time(*goal.TargetDate)))
+		goal.TargetDate = &target
+	}
+
+	hundred := decimal.New(100, 0)
+	seen := make(map[string]bool)
+	names := pq.StringArray{}
+	for i := range goal.Wallets {
+		link := &goal.Wallets[i]
+		if seen[link.Wallet] {
+			return fmt.Errorf("wallet %s is linked twice", link.Wallet)
+		}
+		seen[link.Wallet] = true
+		names = append(names, link.Wallet)
+
+		if link.Share.IsZero() {
+			link.Share = hundred
+		}
+		if link.Share.Sign() < 0 || link.Share.GreaterThan(hundred) {
+			return errors.New("share must be between 0 and 100")
+		}
+
+		wallet, err := applyToWallet(uow, link.Wallet, one, goal.UserId)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			goal.Currency = wallet.Currency
+		}
+		if wallet.Currency != goal.Currency {
+			return fmt.Errorf(
+				"wallets of a goal must share one currency, %s is in %s",
+				wallet.Name,
+				wallet.Currency,
+			)
+		}
+	}
+
+	filter := _GoalShareFilter{
+		ID:      goal.ID,
+		Wallets: names,
+		UserId:  goal.UserId,
+	}
+	mapper := orm.NewGoalMapper(uow, GoalWallet{})
+
+	tmp, err := mapper.Shared(&filter)
+	if err != nil {
+		return err
+	}
+	for _, shared := range *(tmp.(*[]GoalWallet)) {
+		for _, link := range goal.Wallets {
+			if link.Wallet != shared.Wallet {
+				continue
+			}
+			if shared.Share.Add(link.Share).GreaterThan(hundred) {
+				return fmt.Errorf(
+					"other goals count %s%% of %s already",
+					shared.Share,
+					shared.Wallet,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// link links the wallets to the goal as part of uow
+func (goal *Goal) link(uow *orm.UnitOfWork, wallets []GoalWallet) error {
+	mapper := orm.NewGoalMapper(uow, GoalWallet{})
+
+	goal.Wallets = make([]GoalWallet, len(wallets))
+	for i, wallet := range wallets {
+		wallet.GoalID = goal.ID
+		wallet.UserId = goal.UserId
+
+		tmp, err := mapper.Link(&wallet)
+		if err != nil {
+			return err
+		}
+		goal.Wallets[i] = *(tmp.(*GoalWallet))
+	}
+
+	return nil
+}
+
+// linkedWallets fills in the wallets linked to each of goals
+func linkedWallets(goals []Goal, userId string) error {
+	if len(goals) == 0 {
+		return nil
+	}
+
+	ids := _GoalIDs{IDs: make(pq.StringArray, len(goals)), UserId: userId}
+	for i, goal := range goals {
+		ids.IDs[i] = goal.ID
+	}
+
+	mapper := orm.NewGoalMapper(nil, GoalWallet{})
+
+	tmp, err := mapper.Links(&ids)
+	if err != nil {
+		return err
+	}
+
+	byGoal := make(map[string][]GoalWallet)
+	for _, wallet := range *(tmp.(*[]GoalWallet)) {
+		byGoal[wallet.GoalID] = append(byGoal[wallet.GoalID], wallet)
+	}
+	for i := range goals {
+		goals[i].Wallets = byGoal[goals[i].ID]
+		if goals[i].Wallets == nil {
+			goals[i].Wallets = []GoalWallet{}
+		}
+	}
+
+	return nil
+}
+
+// monthsUntil returns how many monthly contributions from day on fall
+// before target, none once target has come
+func monthsUntil(day time.Time, target time.Time) int {
+	if !day.Before(target) {
+		return 0
+	}
+
+	months := monthsBetween(day, target)
+	if day.AddDate(0, months, 0).Before(target) {
+		months++
+	}
+	return months
+}
+
+func applyToGoal(id string, op operation, userId string) (*Goal, error) {
+	goal := Goal{ID: id, UserId: userId}
+	mapper := orm.NewGoalMapper(nil, goal)
+
+	var tmp interface{}
+	var err error
+	switch op {
+	case delete:
+		tmp, err = mapper.Delete(&goal)
+	case one:
+		tmp, err = mapper.One(&goal)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Goal), nil
+}
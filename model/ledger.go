@@ -0,0 +1,334 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/shopspring/decimal"
+)
+
+// LedgerIssue the structure represents something wrong in the ledger of a
+// user: a wallet whose stored balance drifted off what its opening balance
+// and its postings add up to, or a transaction which lacks postings
+type LedgerIssue struct {
+	Kind            constant.LedgerIssueKind `json:"kind"`
+	Wallet          string                   `json:"wallet,omitempty"`
+	TransactionID   string                   `json:"transaction_id,omitempty"`
+	Balance         *decimal.Decimal         `json:"balance,omitempty"`
+	ComputedBalance *decimal.Decimal         `json:"computed_balance,omitempty"`
+	Detail          string                   `json:"detail,omitempty"`
+	UserId          string                   `json:"userId"`
+}
+
+// LedgerRepair the structure is the audit record of a repair made to the
+// ledger of a user
+type LedgerRepair struct {
+	ID            string                   `json:"id" db:"id"`
+	Kind          constant.LedgerIssueKind `json:"kind" db:"kind"`
+	Wallet        string                   `json:"wallet" db:"wallet"`
+	TransactionID *string                  `json:"transaction_id" db:"transaction_id"`
+	OldBalance    *decimal.Decimal         `json:"old_balance" db:"old_balance"`
+	NewBalance    *decimal.Decimal         `json:"new_balance" db:"new_balance"`
+	Detail        string                   `json:"detail" db:"detail"`
+	CreatedAt     time.Time                `json:"created_at" db:"created_at"`
+	UserId        string                   `json:"userId" db:"user_id"`
+}
+
+// LedgerReport the structure is what a check of the ledger found, and what
+// was repaired when it was asked to
+type LedgerReport struct {
+	Wallets int            `json:"wallets"`
+	Issues  []LedgerIssue  `json:"issues"`
+	Repairs []LedgerRepair `json:"repairs"`
+}
+
+// pass this to ORM
+type _WalletBalance struct {
+	Wallet          string          `db:"wallet"`
+	Balance         decimal.Decimal `db:"balance"`
+	ComputedBalance decimal.Decimal `db:"computed_balance"`
+	UserId          string          `db:"user_id"`
+}
+
+// pass this to ORM
+type _BrokenTx struct {
+	TransactionID string                   `db:"transaction_id"`
+	Type          constant.TransactionType `db:"type"`
+	Amount        decimal.Decimal          `db:"amount"`
+	Wallet        string                   `db:"wallet"`
+	SrcCount      int                      `db:"src_count"`
+	DstCount      int                      `db:"dst_count"`
+	UserId        string                   `db:"user_id"`
+}
+
+// CheckLedger recomputes the balance of each wallet from its opening balance
+// and its postings, and looks for transactions with no postings and transfers
+// with only one, for a user or for every user when userId is empty.
+//
+// When repair is set, all in one unit of work, a broken transaction is
+// removed along with whatever postings it has, and then each wallet whose
+// balance drifted is set to the balance it adds up to. Every repair leaves an
+// audit record.
+//
+// A wallet made before wallets had an opening balance got one backfilled
+// from its stored balance, so drift from before then is part of its opening
+// balance and cannot be seen here. Each such backfill has an audit record of
+// kind OPENING_BALANCE.
+func CheckLedger(repair bool, userId string) (*LedgerReport, error) {
+	report := LedgerReport{
+		Issues:  []LedgerIssue{},
+		Repairs: []LedgerRepair{},
+	}
+	var attachments []Attachment
+
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		broken, err := brokenTxs(uow, userId)
+		if err != nil {
+			return err
+		}
+		balances, err := walletBalances(uow, userId)
+		if err != nil {
+			return err
+		}
+
+		report.Wallets = len(balances)
+		for _, tx := range broken {
+			report.Issues = append(report.Issues, tx.toIssue())
+		}
+		for _, balance := range balances {
+			if !balance.drifted() {
+				continue
+			}
+			report.Issues = append(report.Issues, balance.toIssue())
+		}
+
+		if !repair {
+			return nil
+		}
+
+		for _, tx := range broken {
+			// attachments go along with the transaction, so they are read
+			// beforehand
+			txAttachments, err := listAttachments(
+				uow,
+				tx.TransactionID,
+				tx.UserId,
+			)
+			if err != nil {
+				return err
+			}
+
+			r, err := removeBrokenTx(uow, tx)
+			if err != nil {
+				return err
+			}
+
+			attachments = append(attachments, txAttachments...)
+			report.Repairs = append(report.Repairs, *r)
+		}
+
+		// the postings of the broken transactions are gone, so balances add up
+		// to something else than before
+		if len(broken) > 0 {
+			balances, err = walletBalances(uow, userId)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, balance := range balances {
+			if !balance.drifted() {
+				continue
+			}
+
+			r, err := rebalanceWallet(uow, balance.Wallet, balance.UserId)
+			if err != nil {
+				return err
+			}
+			if r != nil {
+				report.Repairs = append(report.Repairs, *r)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	removeContents(attachments)
+	return &report, nil
+}
+
+// ListLedgerRepairs returns a page of the audit records of the repairs made
+// to the ledger of the user
+func ListLedgerRepairs(
+	page Page,
+	userId string,
+) ([]LedgerRepair, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewLedgerMapper(nil, LedgerRepair{})
+
+	tmp, err := mapper.Many(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repairs := *(tmp.(*[]LedgerRepair))
+	length, info := page.cut(len(repairs), func(i int) cursor {
+		return cursor{CreatedAt: repairs[i].CreatedAt, Key: repairs[i].ID}
+	})
+
+	return repairs[:length], info, nil
+}
+
+func walletBalances(
+	uow *orm.UnitOfWork,
+	userId string,
+) ([]_WalletBalance, error) {
+	balance := _WalletBalance{UserId: userId}
+	mapper := orm.NewLedgerMapper(uow, balance)
+
+	tmp, err := mapper.Balances(&balance)
+	if err != nil {
+		return nil, err
+	}
+
+	return *(tmp.(*[]_WalletBalance)), nil
+}
+
+func brokenTxs(uow *orm.UnitOfWork, userId string) ([]_BrokenTx, error) {
+	tx := _BrokenTx{UserId: userId}
+	mapper := orm.NewLedgerMapper(uow, tx)
+
+	tmp, err := mapper.Broken(&tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return *(tmp.(*[]_BrokenTx)), nil
+}
+
+// removeBrokenTx purges a broken transaction and its postings, without
+// touching balances, which are rebalanced afterwards
+func removeBrokenTx(
+	uow *orm.UnitOfWork,
+	tx _BrokenTx,
+) (*LedgerRepair, error) {
+	_tx := _Transaction{ID: tx.TransactionID, UserId: tx.UserId}
+	mapper := orm.NewTxMapper(uow, _tx, tx.Type)
+
+	if _, err := mapper.Purge(&_tx); err != nil {
+		return nil, err
+	}
+
+	issue := tx.toIssue()
+	return recordRepair(uow, LedgerRepair{
+		Kind:          issue.Kind,
+		Wallet:        tx.Wallet,
+		TransactionID: &tx.TransactionID,
+		Detail:        "removed " + issue.Detail,
+		UserId:        tx.UserId,
+	})
+}
+
+// rebalanceWallet sets the balance of a wallet to what it adds up to, nothing
+// is recorded when it already does once the wallet is locked
+func rebalanceWallet(
+	uow *orm.UnitOfWork,
+	wallet string,
+	userId string,
+) (*LedgerRepair, error) {
+	balance := _WalletBalance{Wallet: wallet, UserId: userId}
+	mapper := orm.NewLedgerMapper(uow, balance)
+
+	tmp, err := mapper.LockWallet(&balance)
+	if err != nil {
+		return nil, err
+	}
+	old := tmp.(*_WalletBalance).Balance
+
+	tmp, err = mapper.Rebalance(&balance)
+	if err != nil {
+		return nil, err
+	}
+	rebalanced := tmp.(*_WalletBalance).Balance
+
+	if rebalanced.Equal(old) {
+		return nil, nil
+	}
+
+	return recordRepair(uow, LedgerRepair{
+		Kind:       constant.LedgerIssueKinds().Drift,
+		Wallet:     wallet,
+		OldBalance: &old,
+		NewBalance: &rebalanced,
+		Detail:     fmt.Sprintf("balance was off by %s", old.Sub(rebalanced)),
+		UserId:     userId,
+	})
+}
+
+func recordRepair(
+	uow *orm.UnitOfWork,
+	r LedgerRepair,
+) (*LedgerRepair, error) {
+	mapper := orm.NewLedgerMapper(uow, r)
+
+	tmp, err := mapper.Insert(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*LedgerRepair), nil
+}
+
+func (balance *_WalletBalance) drifted() bool {
+	return !balance.Balance.Equal(balance.ComputedBalance)
+}
+
+func (balance *_WalletBalance) toIssue() LedgerIssue {
+	return LedgerIssue{
+		Kind:            constant.LedgerIssueKinds().Drift,
+		Wallet:          balance.Wallet,
+		Balance:         &balance.Balance,
+		ComputedBalance: &balance.ComputedBalance,
+		Detail: fmt.Sprintf(
+			"balance is off by %s",
+			balance.Balance.Sub(balance.ComputedBalance),
+		),
+		UserId: balance.UserId,
+	}
+}
+
+func (tx *_BrokenTx) toIssue() LedgerIssue {
+	kinds := constant.LedgerIssueKinds()
+
+	kind := kinds.Malformed
+	switch {
+	case tx.SrcCount == 0 && tx.DstCount == 0:
+		kind = kinds.Orphan
+	case tx.Type == constant.TransactionTypes().Transfer &&
+		tx.SrcCount+tx.DstCount == 1:
+		kind = kinds.HalfTransfer
+	}
+
+	return LedgerIssue{
+		Kind:          kind,
+		Wallet:        tx.Wallet,
+		TransactionID: tx.TransactionID,
+		Detail: fmt.Sprintf(
+			"%s of %s with %d source and %d destination postings",
+			tx.Type,
+			tx.Amount,
+			tx.SrcCount,
+			tx.DstCount,
+		),
+		UserId: tx.UserId,
+	}
+}
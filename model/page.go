@@ -0,0 +1,93 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/expenseledger/web-service/orm"
+)
+
+// Page the structure asks for the part of a list which comes after Cursor,
+// at most Limit items of it, a zero Limit asks for the rest of the list
+type Page struct {
+	Limit  int
+	Cursor string
+}
+
+// PageInfo the structure tells whether a list goes on after a page and the
+// cursor to get the next page with
+type PageInfo struct {
+	NextCursor string
+	HasMore    bool
+}
+
+// cursor the structure is the key of the last item of a page, it reaches
+// clients only as an opaque string
+type cursor struct {
+	OccurredAt *time.Time `json:"o,omitempty"`
+	CreatedAt  time.Time  `json:"c"`
+	Key        string     `json:"k"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// toPage turns the page into the parameters of orm.Page, one more item than
+// the limit is asked for to find out whether there is a next page
+func (page *Page) toPage() (*orm.Page, error) {
+	var _page orm.Page
+
+	if page.Limit < 0 {
+		return nil, errors.New("limit must not be negative")
+	}
+	if page.Limit > 0 {
+		limit := page.Limit + 1
+		_page.Limit = &limit
+	}
+
+	if page.Cursor == "" {
+		return &_page, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Key == "" {
+		return nil, errInvalidCursor
+	}
+
+	_page.AfterOccurredAt = c.OccurredAt
+	_page.AfterCreatedAt = &c.CreatedAt
+	_page.AfterKey = &c.Key
+
+	return &_page, nil
+}
+
+// cut trims the extra item asked for by toPage off a list of length items,
+// it returns the length of the page and the page info with the cursor made
+// by keyOf from the last item of the page
+func (page *Page) cut(length int, keyOf func(i int) cursor) (int, *PageInfo) {
+	var info PageInfo
+
+	if page.Limit <= 0 || length <= page.Limit {
+		return length, &info
+	}
+
+	length = page.Limit
+	info.HasMore = true
+
+	b, _ := json.Marshal(keyOf(length - 1))
+	info.NextCursor = base64.RawURLEncoding.EncodeToString(b)
+
+	return length, &info
+}
+
+// pass this to ORM when listing the items of a user
+type _UserPage struct {
+	UserId string `db:"user_id"`
+	orm.Page
+}
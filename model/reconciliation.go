@@ -0,0 +1,254 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Reconciliation the structure represents matching a wallet against a bank
+// statement. Transactions are ticked as cleared until the cleared balance
+// of the wallet, which counts only cleared and reconciled transactions up to
+// StatementDate, meets StatementBalance. Finishing the reconciliation locks
+// the cleared transactions it covers as reconciled.
+type Reconciliation struct {
+	ID               string           `json:"id" db:"id"`
+	Wallet           string           `json:"wallet" db:"wallet"`
+	StatementDate    date.Date        `json:"statement_date" db:"statement_date"`
+	StatementBalance decimal.Decimal  `json:"statement_balance" db:"statement_balance"`
+	ClearedBalance   *decimal.Decimal `json:"cleared_balance,omitempty" db:"cleared_balance"`
+	Difference       *decimal.Decimal `json:"difference,omitempty" db:"-"`
+	ReconciledCount  int              `json:"reconciled_count" db:"reconciled_count"`
+	FinishedAt       *time.Time       `json:"finished_at" db:"finished_at"`
+	CreatedAt        time.Time        `json:"-" db:"created_at"`
+	UserId           string           `json:"userId" db:"user_id"`
+}
+
+// pass this to ORM when listing reconciliations
+type _ReconciliationPage struct {
+	Wallet string `db:"wallet"`
+	UserId string `db:"user_id"`
+	orm.Page
+}
+
+// StartReconciliation opens a reconciliation of a bank account or a credit
+// wallet against the statement ending on statementDate, a wallet has at most
+// one open reconciliation
+func StartReconciliation(
+	wallet string,
+	statementDate date.Date,
+	statementBalance decimal.Decimal,
+	userId string,
+) (*Reconciliation, error) {
+	if time.Time(statementDate).IsZero() {
+		return nil, errors.New("statement date is required")
+	}
+
+	var r *Reconciliation
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		w, err := applyToWallet(uow, wallet, one, userId)
+		if err != nil {
+			return err
+		}
+
+		walletTypes := constant.WalletTypes()
+		if w.Type != walletTypes.BankAccount && w.Type != walletTypes.Credit {
+			return errors.New(
+				"only a bank account or a credit wallet can be reconciled",
+			)
+		}
+
+		_r := Reconciliation{
+			Wallet:           wallet,
+			StatementDate:    statementDate,
+			StatementBalance: statementBalance,
+			UserId:           userId,
+		}
+		mapper := orm.NewReconciliationMapper(uow, _r)
+
+		tmp, err := mapper.Insert(&_r)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return errors.New("wallet already has an open reconciliation")
+		}
+		if err != nil {
+			return err
+		}
+
+		r, err = summarizeReconciliation(uow, tmp.(*Reconciliation).ID, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetReconciliation returns matching reconciliation with its cleared balance
+// and how far the balance is off the statement
+func GetReconciliation(id string, userId string) (*Reconciliation, error) {
+	return summarizeReconciliation(nil, id, userId)
+}
+
+// TickReconciliation marks transactions of the wallet of an open
+// reconciliation as cleared, or back as pending, and returns the
+// reconciliation as it is then
+func TickReconciliation(
+	id string,
+	ids []string,
+	cleared bool,
+	userId string,
+) (*Reconciliation, error) {
+	status := constant.TransactionStatuses().Pending
+	if cleared {
+		status = constant.TransactionStatuses().Cleared
+	}
+
+	var r *Reconciliation
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		open, err := lockReconciliation(uow, id, userId)
+		if err != nil {
+			return err
+		}
+
+		err = setTxStatus(uow, ids, status, open.Wallet, userId)
+		if err != nil {
+			return err
+		}
+
+		r, err = summarizeReconciliation(uow, id, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// FinishReconciliation closes an open reconciliation whose cleared balance
+// meets the statement, the cleared transactions up to the statement date are
+// locked as reconciled
+func FinishReconciliation(id string, userId string) (*Reconciliation, error) {
+	var r *Reconciliation
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		if _, err := lockReconciliation(uow, id, userId); err != nil {
+			return err
+		}
+
+		summary, err := summarizeReconciliation(uow, id, userId)
+		if err != nil {
+			return err
+		}
+		if !summary.Difference.IsZero() {
+			return fmt.Errorf(
+				"cleared balance is off the statement by %s",
+				summary.Difference,
+			)
+		}
+
+		_r := Reconciliation{ID: id, UserId: userId}
+		mapper := orm.NewReconciliationMapper(uow, _r)
+
+		tmp, err := mapper.Finish(&_r)
+		if err != nil {
+			return err
+		}
+
+		r = tmp.(*Reconciliation)
+		r.ClearedBalance = summary.ClearedBalance
+		r.Difference = summary.Difference
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// CancelReconciliation removes an open reconciliation, the transactions
+// ticked in it stay cleared
+func CancelReconciliation(id string, userId string) (*Reconciliation, error) {
+	r := Reconciliation{ID: id, UserId: userId}
+	mapper := orm.NewReconciliationMapper(nil, r)
+
+	tmp, err := mapper.Delete(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Reconciliation), nil
+}
+
+// ListReconciliations returns a page of the reconciliations of the user, of
+// one wallet if it is not empty
+func ListReconciliations(
+	wallet string,
+	page Page,
+	userId string,
+) ([]Reconciliation, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewReconciliationMapper(nil, Reconciliation{})
+
+	tmp, err := mapper.Many(&_ReconciliationPage{
+		Wallet: wallet,
+		UserId: userId,
+		Page:   *_page,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rs := *(tmp.(*[]Reconciliation))
+	length, info := page.cut(len(rs), func(i int) cursor {
+		return cursor{CreatedAt: rs[i].CreatedAt, Key: rs[i].ID}
+	})
+
+	return rs[:length], info, nil
+}
+
+func lockReconciliation(
+	uow *orm.UnitOfWork,
+	id string,
+	userId string,
+) (*Reconciliation, error) {
+	r := Reconciliation{ID: id, UserId: userId}
+	mapper := orm.NewReconciliationMapper(uow, r)
+
+	tmp, err := mapper.Lock(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Reconciliation), nil
+}
+
+func summarizeReconciliation(
+	uow *orm.UnitOfWork,
+	id string,
+	userId string,
+) (*Reconciliation, error) {
+	r := Reconciliation{ID: id, UserId: userId}
+	mapper := orm.NewReconciliationMapper(uow, r)
+
+	tmp, err := mapper.Summary(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := tmp.(*Reconciliation)
+	difference := summary.StatementBalance.Sub(*summary.ClearedBalance)
+	summary.Difference = &difference
+	return summary, nil
+}
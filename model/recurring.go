@@ -0,0 +1,506 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/shopspring/decimal"
+)
+
+// RecurringRule the structure represents a transaction which is posted again
+// and again on a schedule. The schedule repeats every Interval days, weeks,
+// months or years from StartDate, a monthly one on DayOfMonth if given, and
+// ends after EndDate or after Count occurrences. A transfer between wallets
+// of different currencies takes DstAmount or Rate, as a transaction does.
+// LastError tells why the rule could not be posted when it last failed, it
+// is cleared once the rule is posted or updated.
+type RecurringRule struct {
+	ID          string                   `json:"id" db:"id"`
+	Type        constant.TransactionType `json:"type" db:"type"`
+	Amount      decimal.Decimal          `json:"amount" db:"amount"`
+	DstAmount   *decimal.Decimal         `json:"dst_amount" db:"dst_amount"`
+	Rate        *decimal.Decimal         `json:"rate" db:"rate"`
+	From        string                   `json:"src_wallet" db:"src_wallet"`
+	To          string                   `json:"dst_wallet" db:"dst_wallet"`
+	Category    string                   `json:"category" db:"category"`
+	Description string                   `json:"description" db:"description"`
+	Frequency   constant.Frequency       `json:"frequency" db:"frequency"`
+	Interval    int                      `json:"interval" db:"repeat_every"`
+	DayOfMonth  *int                     `json:"day_of_month" db:"day_of_month"`
+	StartDate   date.Date                `json:"start_date" db:"start_date"`
+	EndDate     *date.Date               `json:"end_date" db:"end_date"`
+	Count       *int                     `json:"count" db:"max_occurrences"`
+	Occurrences int                      `json:"occurrences" db:"occurrences"`
+	NextDate    *date.Date               `json:"next_date" db:"next_date"`
+	LastDate    *date.Date               `json:"last_date" db:"last_date"`
+	LastError   *string                  `json:"last_error" db:"last_error"`
+	FailedAt    *time.Time               `json:"failed_at" db:"failed_at"`
+	CreatedAt   time.Time                `json:"-" db:"created_at"`
+	UserId      string                   `json:"userId" db:"user_id"`
+}
+
+// pass this to ORM when looking for due rules
+type _RecurringDue struct {
+	ID     string    `db:"id"`
+	Today  time.Time `db:"today"`
+	UserId string    `db:"user_id"`
+}
+
+// pass this to ORM
+type _RecurringPosting struct {
+	RuleID        string    `db:"rule_id"`
+	OccurredOn    date.Date `db:"occurred_on"`
+	TransactionID string    `db:"transaction_id"`
+	UserId        string    `db:"user_id"`
+}
+
+// CreateRecurringRule inserts a recurring rule, its first occurrence is the
+// first date of its schedule
+func CreateRecurringRule(
+	rule RecurringRule,
+	userId string,
+) (*RecurringRule, error) {
+	rule.UserId = userId
+	rule.Occurrences = 0
+	rule.LastDate = nil
+	if err := rule.check(); err != nil {
+		return nil, err
+	}
+	rule.schedule()
+
+	mapper := orm.NewRecurringMapper(nil, rule)
+
+	tmp, err := mapper.Insert(&rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*RecurringRule), nil
+}
+
+// GetRecurringRule returns matching recurring rule from DB
+func GetRecurringRule(id string, userId string) (*RecurringRule, error) {
+	return applyToRecurringRule(id, one, userId)
+}
+
+// DeleteRecurringRule removes recurring rule from DB, the transactions it
+// has posted are kept
+func DeleteRecurringRule(id string, userId string) (*RecurringRule, error) {
+	return applyToRecurringRule(id, delete, userId)
+}
+
+// UpdateRecurringRule rewrites the template and the schedule of a recurring
+// rule, its next occurrence is the first date of the new schedule after the
+// last one it was posted on
+func UpdateRecurringRule(
+	rule RecurringRule,
+	userId string,
+) (*RecurringRule, error) {
+	rule.UserId = userId
+	if err := rule.check(); err != nil {
+		return nil, err
+	}
+
+	var updated *RecurringRule
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		mapper := orm.NewRecurringMapper(uow, rule)
+
+		tmp, err := mapper.One(&rule)
+		if err != nil {
+			return err
+		}
+
+		old := tmp.(*RecurringRule)
+		rule.Occurrences = old.Occurrences
+		rule.LastDate = old.LastDate
+		rule.schedule()
+
+		tmp, err = mapper.Update(&rule)
+		if err != nil {
+			return err
+		}
+
+		updated = tmp.(*RecurringRule)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// ListRecurringRules returns a page of the recurring rules of the user
+func ListRecurringRules(
+	page Page,
+	userId string,
+) ([]RecurringRule, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewRecurringMapper(nil, RecurringRule{})
+
+	tmp, err := mapper.Many(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules := *(tmp.(*[]RecurringRule))
+	length, info := page.cut(len(rules), func(i int) cursor {
+		return cursor{CreatedAt: rules[i].CreatedAt, Key: rules[i].ID}
+	})
+
+	return rules[:length], info, nil
+}
+
+// PostDueRecurringRules posts every occurrence of every recurring rule due by
+// now. Each occurrence is posted in a unit of work of its own, together with
+// a record of it which keeps it from being posted twice. A failing rule does
+// not stop the others, the first error is returned with the number of posted
+// occurrences.
+func PostDueRecurringRules(now time.Time) (int, error) {
+	today := toDay(now)
+	mapper := orm.NewRecurringMapper(nil, _RecurringDue{})
+
+	tmp, err := mapper.Due(&_RecurringDue{Today: today})
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		posted   int
+		firstErr error
+	)
+
+	for _, due := range *(tmp.(*[]_RecurringDue)) {
+		n, err := postRecurringRule(due.ID, due.UserId, today)
+		posted += n
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return posted, firstErr
+}
+
+func postRecurringRule(id string, userId string, today time.Time) (int, error) {
+	var posted int
+
+	for {
+		var done bool
+
+		err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+			rule, err := lockDueRecurringRule(uow, id, userId, today)
+			if err == sql.ErrNoRows {
+				done = true
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			isNew, err := postOccurrence(uow, rule)
+			if err != nil {
+				return err
+			}
+			if isNew {
+				posted++
+			}
+
+			rule.Occurrences++
+			rule.LastDate = rule.NextDate
+			rule.schedule()
+
+			mapper := orm.NewRecurringMapper(uow, *rule)
+			_, err = mapper.Update(rule)
+			return err
+		})
+
+		if err != nil {
+			if failErr := failRecurringRule(id, userId, err); failErr != nil {
+				log.Println("Error recording failure of rule", id, failErr)
+			}
+			return posted, err
+		}
+		if done {
+			return posted, nil
+		}
+	}
+}
+
+// failRecurringRule records on the rule why it could not be posted, so the
+// failure shows when the rule is read, the rule stays due and is tried again
+func failRecurringRule(id string, userId string, cause error) error {
+	message := cause.Error()
+	rule := RecurringRule{ID: id, LastError: &message, UserId: userId}
+	mapper := orm.NewRecurringMapper(nil, rule)
+
+	_, err := mapper.Fail(&rule)
+	return err
+}
+
+func lockDueRecurringRule(
+	uow *orm.UnitOfWork,
+	id string,
+	userId string,
+	today time.Time,
+) (*RecurringRule, error) {
+	mapper := orm.NewRecurringMapper(uow, RecurringRule{})
+
+	tmp, err := mapper.Lock(&_RecurringDue{ID: id, Today: today, UserId: userId})
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*RecurringRule), nil
+}
+
+// postOccurrence creates the transaction of the next occurrence of the rule,
+// unless it was already posted, and tells whether it created one
+func postOccurrence(uow *orm.UnitOfWork, rule *RecurringRule) (bool, error) {
+	posting := _RecurringPosting{
+		RuleID:     rule.ID,
+		OccurredOn: *rule.NextDate,
+		UserId:     rule.UserId,
+	}
+	mapper := orm.NewRecurringMapper(uow, posting)
+
+	_, err := mapper.Posted(&posting)
+	if err == nil {
+		return false, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	input := TransactionInput{
+		Amount:      rule.Amount,
+		DstAmount:   rule.DstAmount,
+		Rate:        rule.Rate,
+		Type:        rule.Type,
+		From:        rule.From,
+		To:          rule.To,
+		Category:    rule.Category,
+		Description: rule.Description,
+		Date:        *rule.NextDate,
+	}
+
+	tx, _, err := createTx(uow, input, rule.UserId)
+	if err != nil {
+		return false, err
+	}
+
+	posting.TransactionID = tx.ID
+	if _, err := mapper.Post(&posting); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func applyToRecurringRule(
+	id string,
+	op operation,
+	userId string,
+) (*RecurringRule, error) {
+	rule := RecurringRule{ID: id, UserId: userId}
+	mapper := orm.NewRecurringMapper(nil, rule)
+
+	var tmp interface{}
+	var err error
+	switch op {
+	case delete:
+		tmp, err = mapper.Delete(&rule)
+	case one:
+		tmp, err = mapper.One(&rule)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*RecurringRule), nil
+}
+
+// check validates the rule and fills in its defaults
+func (rule *RecurringRule) check() error {
+	from, to, err := walletsOfTxType(rule.Type, rule.From, rule.To)
+	if err != nil {
+		return err
+	}
+	rule.From, rule.To = from, to
+
+	if rule.Category == "" {
+		return errors.New("category is required")
+	}
+	err = checkCategories(nil, []string{rule.Category}, rule.Type, rule.UserId)
+	if err != nil {
+		return err
+	}
+
+	input := TransactionInput{
+		Amount:    rule.Amount,
+		DstAmount: rule.DstAmount,
+		Rate:      rule.Rate,
+		Type:      rule.Type,
+		From:      rule.From,
+		To:        rule.To,
+	}
+	if _, err := input.checkCurrencies(nil, rule.UserId); err != nil {
+		return err
+	}
+
+	if !isFrequency(rule.Frequency) {
+		return errors.New("unknown frequency")
+	}
+
+	if rule.Interval < 0 {
+		return errors.New("interval must be positive")
+	}
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	}
+
+	if dom := rule.DayOfMonth; dom != nil {
+		if rule.Frequency != constant.Frequencies().Monthly {
+			return errors.New("day of month is only for monthly rules")
+		}
+		if *dom < 1 || *dom > 31 {
+			return errors.New("day of month must be between 1 and 31")
+		}
+	}
+
+	start := time.Time(rule.StartDate)
+	if start.IsZero() {
+		return errors.New("start date is required")
+	}
+	rule.StartDate = date.Date(toDay(start))
+
+	if end := rule.EndDate; end != nil {
+		if time.Time(*end).Before(start) {
+			return errors.New("end date must not be before start date")
+		}
+	}
+
+	if count := rule.Count; count != nil && *count <= 0 {
+		return errors.New("count must be positive")
+	}
+
+	return nil
+}
+
+// schedule sets the next date of the rule to the first date of its schedule
+// after the last one it was posted on, or to nil once the schedule is over
+func (rule *RecurringRule) schedule() {
+	rule.NextDate = nil
+
+	if count := rule.Count; count != nil && rule.Occurrences >= *count {
+		return
+	}
+
+	from := time.Time(rule.StartDate)
+	if last := rule.LastDate; last != nil {
+		if next := time.Time(*last).AddDate(0, 0, 1); next.After(from) {
+			from = next
+		}
+	}
+
+	next := rule.firstOnOrAfter(from)
+	if end := rule.EndDate; end != nil && next.After(time.Time(*end)) {
+		return
+	}
+
+	d := date.Date(next)
+	rule.NextDate = &d
+}
+
+// firstOnOrAfter returns the first date of the schedule which is not before
+// from, which itself is not before the start date
+func (rule *RecurringRule) firstOnOrAfter(from time.Time) time.Time {
+	start := time.Time(rule.StartDate)
+	frequencies := constant.Frequencies()
+
+	// start a little before the answer and walk forward, so that the walk
+	// is short however long the rule has been running
+	var k int
+	switch rule.Frequency {
+	case frequencies.Daily:
+		k = int(from.Sub(start).Hours()/24) / rule.Interval
+	case frequencies.Weekly:
+		k = int(from.Sub(start).Hours()/24/7) / rule.Interval
+	case frequencies.Monthly:
+		k = monthsBetween(start, from) / rule.Interval
+	case frequencies.Yearly:
+		k = (from.Year() - start.Year()) / rule.Interval
+	}
+	if k > 0 {
+		k--
+	}
+
+	for {
+		occurrence := rule.occurrence(k)
+		if !occurrence.Before(from) && !occurrence.Before(start) {
+			return occurrence
+		}
+		k++
+	}
+}
+
+// occurrence returns the k-th date of the schedule counted from the start
+// date, a monthly date falls on the day of month of the rule and a day of
+// month missing from a month falls on its last day instead
+func (rule *RecurringRule) occurrence(k int) time.Time {
+	start := time.Time(rule.StartDate)
+	step := k * rule.Interval
+	frequencies := constant.Frequencies()
+
+	switch rule.Frequency {
+	case frequencies.Daily:
+		return start.AddDate(0, 0, step)
+	case frequencies.Weekly:
+		return start.AddDate(0, 0, 7*step)
+	case frequencies.Monthly:
+		day := start.Day()
+		if rule.DayOfMonth != nil {
+			day = *rule.DayOfMonth
+		}
+		return dayOfMonth(start.Year(), start.Month()+time.Month(step), day)
+	default:
+		return dayOfMonth(start.Year()+step, start.Month(), start.Day())
+	}
+}
+
+// dayOfMonth returns the day of the month, or the last day of the month if
+// the month is shorter, it normalizes months out of range like time.Date
+func dayOfMonth(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func monthsBetween(from time.Time, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
+
+func toDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func isFrequency(f constant.Frequency) bool {
+	for _, frequency := range constant.ListFrequencies() {
+		if string(f) == frequency {
+			return true
+		}
+	}
+	return false
+}
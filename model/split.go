@@ -0,0 +1,152 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Split the structure represents a part of an expense or an income which is
+// put under a category of its own
+type Split struct {
+	Category string          `json:"category" db:"category"`
+	Amount   decimal.Decimal `json:"amount" db:"amount"`
+	Note     string          `json:"note" db:"note"`
+}
+
+// pass this to ORM
+type _Split struct {
+	TransactionID string          `db:"transaction_id"`
+	Line          int             `db:"line"`
+	Category      string          `db:"category"`
+	Amount        decimal.Decimal `db:"amount"`
+	Note          string          `db:"note"`
+	UserId        string          `db:"user_id"`
+}
+
+// pass this to ORM when listing the splits of transactions
+type _SplitFilter struct {
+	TransactionIDs pq.StringArray `db:"transaction_ids"`
+	UserId         string         `db:"user_id"`
+}
+
+// checkSplits makes sure the splits of a transaction of type t add up to its
+// amount, it returns the category the transaction is filed under, which is
+// the category of its first split when it has any
+func checkSplits(
+	t constant.TransactionType,
+	amount decimal.Decimal,
+	category string,
+	splits []Split,
+) (string, error) {
+	if len(splits) == 0 {
+		if category == "" {
+			return "", errors.New("category is required")
+		}
+		return category, nil
+	}
+
+	if t == constant.TransactionTypes().Transfer {
+		return "", errors.New("a transfer cannot be split")
+	}
+
+	total := decimal.Zero
+	for _, split := range splits {
+		if split.Category == "" {
+			return "", errors.New("category of a split is required")
+		}
+		if split.Amount.Sign() < 0 {
+			return "", errors.New("amount of a split must not be negative")
+		}
+		total = total.Add(split.Amount)
+	}
+
+	if !total.Equal(amount) {
+		return "", errors.New("splits do not add up to the amount")
+	}
+
+	return splits[0].Category, nil
+}
+
+// insertSplits stores the splits of tx and attaches them to it
+func insertSplits(
+	uow *orm.UnitOfWork,
+	tx *Transaction,
+	splits []Split,
+) error {
+	mapper := orm.NewSplitMapper(uow, _Split{})
+	tx.Splits = make([]Split, 0, len(splits))
+
+	for i, split := range splits {
+		_split := _Split{
+			TransactionID: tx.ID,
+			Line:          i + 1,
+			Category:      split.Category,
+			Amount:        split.Amount,
+			Note:          split.Note,
+			UserId:        tx.UserId,
+		}
+
+		tmp, err := mapper.Insert(&_split)
+		if err != nil {
+			return err
+		}
+		tx.Splits = append(tx.Splits, tmp.(*_Split).toSplit())
+	}
+
+	return nil
+}
+
+// deleteSplits removes every split of the transaction
+func deleteSplits(uow *orm.UnitOfWork, id string, userId string) error {
+	mapper := orm.NewSplitMapper(uow, _Split{})
+	_, err := mapper.Delete(&_Split{TransactionID: id, UserId: userId})
+	return err
+}
+
+// loadSplits attaches the stored splits to each of txs
+func loadSplits(uow *orm.UnitOfWork, txs []Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	filter := _SplitFilter{
+		TransactionIDs: make(pq.StringArray, len(txs)),
+		UserId:         txs[0].UserId,
+	}
+	for i, tx := range txs {
+		filter.TransactionIDs[i] = tx.ID
+	}
+
+	mapper := orm.NewSplitMapper(uow, _Split{})
+
+	tmp, err := mapper.Many(&filter)
+	if err != nil {
+		return err
+	}
+
+	splits := make(map[string][]Split)
+	for _, _split := range *(tmp.(*[]_Split)) {
+		splits[_split.TransactionID] = append(
+			splits[_split.TransactionID],
+			_split.toSplit(),
+		)
+	}
+
+	for i := range txs {
+		txs[i].Splits = splits[txs[i].ID]
+	}
+
+	return nil
+}
+
+func (split *_Split) toSplit() Split {
+	return Split{
+		Category: split.Category,
+		Amount:   split.Amount,
+		Note:     split.Note,
+	}
+}
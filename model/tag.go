@@ -0,0 +1,293 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/expenseledger/web-service/orm"
+	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Tag the structure represents a free-form label of transactions, unlike a
+// category a transaction may have any number of tags
+type Tag struct {
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"-" db:"created_at"`
+	UserId    string    `json:"userId" db:"user_id"`
+}
+
+// TagTotal the structure sums up the transactions of a tag in one currency
+type TagTotal struct {
+	Tag      string          `json:"tag" db:"tag"`
+	Currency string          `json:"currency" db:"currency"`
+	Count    int             `json:"count" db:"count"`
+	Expense  decimal.Decimal `json:"expense" db:"expense"`
+	Income   decimal.Decimal `json:"income" db:"income"`
+	Transfer decimal.Decimal `json:"transfer" db:"transfer"`
+}
+
+// pass this to ORM when renaming a tag
+type _TagRename struct {
+	Name    string `db:"name"`
+	NewName string `db:"new_name"`
+	UserId  string `db:"user_id"`
+}
+
+// pass this to ORM
+type _TxTag struct {
+	TransactionID string `db:"transaction_id"`
+	Tag           string `db:"tag"`
+	UserId        string `db:"user_id"`
+}
+
+// pass this to ORM when tagging or untagging a transaction
+type _TxTags struct {
+	TransactionID string         `db:"transaction_id"`
+	Tags          pq.StringArray `db:"tags"`
+	UserId        string         `db:"user_id"`
+}
+
+// pass this to ORM when listing the tags of transactions
+type _TagFilter struct {
+	TransactionIDs pq.StringArray `db:"transaction_ids"`
+	UserId         string         `db:"user_id"`
+}
+
+// pass this to ORM when summing up tags
+type _TagTotalFilter struct {
+	FromDate *time.Time     `db:"from_date"`
+	ToDate   *time.Time     `db:"to_date"`
+	Tags     pq.StringArray `db:"tags"`
+	UserId   string         `db:"user_id"`
+}
+
+// CreateTag inserts tag to DB
+func CreateTag(name string, userId string) (*Tag, error) {
+	name, err := checkTag(name)
+	if err != nil {
+		return nil, err
+	}
+	return applyToTag(name, insert, userId)
+}
+
+// GetTag returns matching tag from DB
+func GetTag(name string, userId string) (*Tag, error) {
+	return applyToTag(name, one, userId)
+}
+
+// DeleteTag removes tag from DB, along with it from every transaction
+func DeleteTag(name string, userId string) (*Tag, error) {
+	return applyToTag(name, delete, userId)
+}
+
+// RenameTag renames a tag, the transactions it is on follow along
+func RenameTag(name string, newName string, userId string) (*Tag, error) {
+	newName, err := checkTag(newName)
+	if err != nil {
+		return nil, err
+	}
+
+	rename := _TagRename{Name: name, NewName: newName, UserId: userId}
+	mapper := orm.NewTagMapper(nil, Tag{})
+
+	tmp, err := mapper.Update(&rename)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Tag), nil
+}
+
+// ListTags returns a page of the tags of the user
+func ListTags(page Page, userId string) ([]Tag, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewTagMapper(nil, Tag{})
+
+	tmp, err := mapper.Many(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags := *(tmp.(*[]Tag))
+	length, info := page.cut(len(tags), func(i int) cursor {
+		return cursor{CreatedAt: tags[i].CreatedAt, Key: tags[i].Name}
+	})
+
+	return tags[:length], info, nil
+}
+
+// TotalTags sums up the transactions of each tag, or of the given tags, which
+// occurred between the dates, both inclusive. A tag has a total for each
+// currency its transactions are in.
+func TotalTags(
+	tags []string,
+	fromDate date.Date,
+	toDate date.Date,
+	userId string,
+) ([]TagTotal, error) {
+	filter := _TagTotalFilter{UserId: userId}
+
+	if from := time.Time(fromDate); !from.IsZero() {
+		filter.FromDate = &from
+	}
+	if to := time.Time(toDate); !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+		filter.ToDate = &to
+	}
+	if len(tags) > 0 {
+		filter.Tags = pq.StringArray(tags)
+	}
+
+	mapper := orm.NewTagMapper(nil, TagTotal{})
+
+	tmp, err := mapper.Totals(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return *(tmp.(*[]TagTotal)), nil
+}
+
+// tagTx puts tags on tx, a tag the user does not have yet is created
+func tagTx(uow *orm.UnitOfWork, tx *Transaction, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_tags, err := checkTags(tags)
+	if err != nil {
+		return err
+	}
+
+	txTags := _TxTags{
+		TransactionID: tx.ID,
+		Tags:          _tags,
+		UserId:        tx.UserId,
+	}
+
+	if _, err := orm.NewTagMapper(uow, Tag{}).Ensure(&txTags); err != nil {
+		return err
+	}
+
+	_, err = orm.NewTagMapper(uow, _TxTag{}).Attach(&txTags)
+	return err
+}
+
+// untagTx takes tags off tx
+func untagTx(uow *orm.UnitOfWork, tx *Transaction, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_tags, err := checkTags(tags)
+	if err != nil {
+		return err
+	}
+
+	txTags := _TxTags{
+		TransactionID: tx.ID,
+		Tags:          _tags,
+		UserId:        tx.UserId,
+	}
+
+	_, err = orm.NewTagMapper(uow, _TxTag{}).Detach(&txTags)
+	return err
+}
+
+// loadTags attaches the names of their tags to each of txs
+func loadTags(uow *orm.UnitOfWork, txs []Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	filter := _TagFilter{
+		TransactionIDs: make(pq.StringArray, len(txs)),
+		UserId:         txs[0].UserId,
+	}
+	for i, tx := range txs {
+		filter.TransactionIDs[i] = tx.ID
+	}
+
+	mapper := orm.NewTagMapper(uow, _TxTag{})
+
+	tmp, err := mapper.Of(&filter)
+	if err != nil {
+		return err
+	}
+
+	tags := make(map[string][]string)
+	for _, txTag := range *(tmp.(*[]_TxTag)) {
+		tags[txTag.TransactionID] = append(
+			tags[txTag.TransactionID],
+			txTag.Tag,
+		)
+	}
+
+	for i := range txs {
+		txs[i].Tags = tags[txs[i].ID]
+	}
+
+	return nil
+}
+
+// reloadTags replaces the tags of tx with those stored
+func reloadTags(uow *orm.UnitOfWork, tx *Transaction) error {
+	txs := []Transaction{*tx}
+	if err := loadTags(uow, txs); err != nil {
+		return err
+	}
+
+	tx.Tags = txs[0].Tags
+	return nil
+}
+
+// checkTag returns the name of a tag without surrounding spaces, which must
+// not be empty
+func checkTag(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name of a tag is required")
+	}
+	return name, nil
+}
+
+func checkTags(tags []string) (pq.StringArray, error) {
+	_tags := make(pq.StringArray, len(tags))
+	for i, tag := range tags {
+		name, err := checkTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		_tags[i] = name
+	}
+	return _tags, nil
+}
+
+func applyToTag(name string, op operation, userId string) (*Tag, error) {
+	t := Tag{Name: name, UserId: userId}
+	mapper := orm.NewTagMapper(nil, t)
+
+	var tmp interface{}
+	var err error
+	switch op {
+	case insert:
+		tmp, err = mapper.Insert(&t)
+	case delete:
+		tmp, err = mapper.Delete(&t)
+	case one:
+		tmp, err = mapper.One(&t)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Tag), nil
+}
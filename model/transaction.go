@@ -3,169 +3,688 @@ package model
 import (
 
 	// dbmodel "github.com/expenseledger/web-service/db/model"
+	"database/sql"
 	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/expenseledger/web-service/constant"
 	"github.com/expenseledger/web-service/orm"
 	"github.com/expenseledger/web-service/pkg/type/date"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
 // Transaction the structure represents a transaction in application layer
 type Transaction struct {
-	ID          string                   `json:"id" db:"id"`
-	From        string                   `json:"src_wallet" db:"src_wallet"`
-	To          string                   `json:"dst_wallet" db:"dst_wallet"`
-	Amount      decimal.Decimal          `json:"amount" db:"amount"`
-	Type        constant.TransactionType `json:"type" db:"type"`
-	Category    string                   `json:"category" db:"category"`
-	Description string                   `json:"description" db:"description"`
-	Date        date.Date                `json:"date"`
-	OccurredAt  time.Time                `json:"-" db:"occurred_at"`
-	UserId      string                   `json:"userId" db:"user_id"`
+	ID             string                     `json:"id" db:"id"`
+	From           string                     `json:"src_wallet" db:"src_wallet"`
+	To             string                     `json:"dst_wallet" db:"dst_wallet"`
+	Amount         decimal.Decimal            `json:"amount" db:"amount"`
+	DstAmount      *decimal.Decimal           `json:"dst_amount,omitempty" db:"dst_amount"`
+	BaseAmount     *decimal.Decimal           `json:"base_amount,omitempty" db:"-"`
+	RunningBalance *decimal.Decimal           `json:"running_balance,omitempty" db:"-"`
+	Type           constant.TransactionType   `json:"type" db:"type"`
+	Category       string                     `json:"category" db:"category"`
+	Splits         []Split                    `json:"splits,omitempty"`
+	Tags           []string                   `json:"tags,omitempty"`
+	Description    string                     `json:"description" db:"description"`
+	Status         constant.TransactionStatus `json:"status" db:"status"`
+	Date           date.Date                  `json:"date"`
+	OccurredAt     time.Time                  `json:"-" db:"occurred_at"`
+	CreatedAt      time.Time                  `json:"-" db:"created_at"`
+	DeletedAt      *time.Time                 `json:"deleted_at,omitempty" db:"deleted_at"`
+	UserId         string                     `json:"userId" db:"user_id"`
 }
 
 // pass this to ORM
 type _Transaction struct {
-	ID          string                   `db:"id"`
-	Wallet      string                   `db:"wallet"`
-	Role        constant.WalletRole      `db:"role"`
-	Type        constant.TransactionType `db:"type"`
-	Amount      decimal.Decimal          `db:"amount"`
-	Category    string                   `db:"category"`
-	Description string                   `db:"description"`
-	OccurredAt  time.Time                `db:"occurred_at"`
-	CreatedAt   time.Time                `db:"created_at"`
-	UserId      string                   `db:"user_id"`
+	ID           string                     `db:"id"`
+	Wallet       string                     `db:"wallet"`
+	Role         constant.WalletRole        `db:"role"`
+	Type         constant.TransactionType   `db:"type"`
+	Amount       decimal.Decimal            `db:"amount"`
+	WalletAmount decimal.Decimal            `db:"wallet_amount"`
+	Category     string                     `db:"category"`
+	Description  string                     `db:"description"`
+	Status       constant.TransactionStatus `db:"status"`
+	OccurredAt   time.Time                  `db:"occurred_at"`
+	CreatedAt    time.Time                  `db:"created_at"`
+	DeletedAt    *time.Time                 `db:"deleted_at"`
+	UserId       string                     `db:"user_id"`
 }
 
 type transactions []_Transaction
 
+var errTxReconciled = errors.New(
+	"transaction is reconciled, unlock it before changing it",
+)
+
+// TransactionInput the structure holds what a transaction is created or
+// updated with. Amount is in the currency of the source wallet, or of the
+// destination wallet of an income, and Currency, when given, must be that
+// currency. A transfer to a wallet of another currency puts DstAmount into
+// it, or Amount converted by Rate.
+type TransactionInput struct {
+	Amount      decimal.Decimal
+	DstAmount   *decimal.Decimal
+	Rate        *decimal.Decimal
+	Currency    string
+	Type        constant.TransactionType
+	From        string
+	To          string
+	Category    string
+	Splits      []Split
+	AddTags     []string
+	RemoveTags  []string
+	Description string
+	Date        date.Date
+}
+
+// AffectedWallets the structure holds the wallets touched by a transaction,
+// as they are after its balance effect was applied or reverted
+type AffectedWallets struct {
+	Src *Wallet
+	Dst *Wallet
+}
+
+// CreateTransction inserts a transaction and applies its balance effect to
+// the affected wallets, all in one unit of work. An expense or an income
+// may be split across categories, the splits must add up to its amount.
 func CreateTransction(
-	amount decimal.Decimal,
-	t constant.TransactionType,
-	from string,
-	to string,
-	category string,
-	description string,
-	d date.Date,
+	input TransactionInput,
 	userId string,
-) (*Transaction, error) {
-	tim := time.Time(d)
-	if tim.IsZero() {
-		tim = time.Now()
+) (*Transaction, *AffectedWallets, error) {
+	var (
+		tx      *Transaction
+		wallets *AffectedWallets
+	)
+
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) (err error) {
+		tx, wallets, err = createTx(uow, input, userId)
+		return
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if txTypes := constant.TransactionTypes(); t != txTypes.Transfer {
-		var wallet string
-		switch t {
-		case txTypes.Expense:
-			wallet = from
-		case txTypes.Income:
-			wallet = to
+	return tx, wallets, nil
+}
+
+// createTx is CreateTransction as part of uow
+func createTx(
+	uow *orm.UnitOfWork,
+	input TransactionInput,
+	userId string,
+) (*Transaction, *AffectedWallets, error) {
+	if err := input.check(uow, userId); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := insertTx(uow, &input, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(input.Splits) > 0 {
+		if err := insertSplits(uow, tx, input.Splits); err != nil {
+			return nil, nil, err
 		}
+	}
 
-		tx, err := createNonTransferTx(
-			amount,
-			t,
-			wallet,
-			category,
-			description,
-			tim,
-			userId,
-		)
+	if err := tagTx(uow, tx, input.AddTags); err != nil {
+		return nil, nil, err
+	}
+	if err := reloadTags(uow, tx); err != nil {
+		return nil, nil, err
+	}
+
+	wallets, err := applyTx(uow, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, wallets, nil
+}
+
+func GetTransaction(id string, userId string) (*Transaction, error) {
+	tx, err := applyToTx(nil, id, one, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := []Transaction{*tx}
+	if err := loadSplits(nil, txs); err != nil {
+		return nil, err
+	}
+	if err := loadTags(nil, txs); err != nil {
+		return nil, err
+	}
+
+	return &txs[0], nil
+}
+
+// DeleteTransaction moves a transaction to the trash and reverts its balance
+// effect on the affected wallets, all in one unit of work. Its splits, tags
+// and attachments stay with it until it is purged from the trash.
+func DeleteTransaction(
+	id string,
+	userId string,
+) (*Transaction, *AffectedWallets, error) {
+	var (
+		tx      *Transaction
+		wallets *AffectedWallets
+	)
+
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) (err error) {
+		tx, err = applyToTx(uow, id, delete, userId)
 		if err != nil {
-			return nil, err
+			return
 		}
-		return tx, nil
+		if tx.Status == constant.TransactionStatuses().Reconciled {
+			return errTxReconciled
+		}
+
+		txs := []Transaction{*tx}
+		if err = loadSplits(uow, txs); err != nil {
+			return
+		}
+		if err = loadTags(uow, txs); err != nil {
+			return
+		}
+		tx.Splits = txs[0].Splits
+		tx.Tags = txs[0].Tags
+
+		wallets, err = revertTx(uow, tx)
+		if err == sql.ErrNoRows {
+			err = errors.New("a wallet of the transaction is in the trash")
+		}
+		return
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	tx := Transaction{
-		From:        from,
-		To:          to,
-		Amount:      amount,
-		Type:        t,
-		Category:    category,
-		Description: description,
-		OccurredAt:  tim,
-		UserId:      userId,
+	return tx, wallets, nil
+}
+
+// RestoreTransaction takes a transaction out of the trash and applies its
+// balance effect to the affected wallets again, all in one unit of work. Its
+// wallets and categories must not be in the trash.
+func RestoreTransaction(
+	id string,
+	userId string,
+) (*Transaction, *AffectedWallets, error) {
+	var (
+		tx      *Transaction
+		wallets *AffectedWallets
+	)
+
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) (err error) {
+		tx, err = applyToTx(uow, id, restore, userId)
+		if err != nil {
+			return
+		}
+
+		txs := []Transaction{*tx}
+		if err = loadSplits(uow, txs); err != nil {
+			return
+		}
+		if err = loadTags(uow, txs); err != nil {
+			return
+		}
+		tx.Splits = txs[0].Splits
+		tx.Tags = txs[0].Tags
+
+		categories := []string{tx.Category}
+		for _, split := range tx.Splits {
+			categories = append(categories, split.Category)
+		}
+		err = checkCategories(uow, categories, tx.Type, userId)
+		if err != nil {
+			return
+		}
+
+		wallets, err = applyTx(uow, tx)
+		if err == sql.ErrNoRows {
+			err = errors.New("a wallet of the transaction is in the trash")
+		}
+		return
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	mapper := orm.NewTxMapper(tx, t)
+	return tx, wallets, nil
+}
 
-	tmp, err := mapper.Insert(&tx)
+// ListTrashedTransactions returns a page of the transactions of the user in
+// the trash, newest first
+func ListTrashedTransactions(
+	page Page,
+	userId string,
+) ([]Transaction, *PageInfo, error) {
+	_page, err := page.toPage()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	tmpTx := tmp.(*Transaction)
-	tmpTx.Date = date.Date(tmpTx.OccurredAt)
-	return tmpTx, nil
+	mapper := orm.NewTxMapper(
+		nil,
+		_Transaction{UserId: userId},
+		constant.TransactionTypes().Expense,
+	)
+
+	tmp, err := mapper.Trash(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_txs := (*transactions)(tmp.(*[]_Transaction))
+	txs := _txs.toTransactions()
+	length, info := page.cut(len(txs), func(i int) cursor {
+		return cursor{
+			OccurredAt: &txs[i].OccurredAt,
+			CreatedAt:  txs[i].CreatedAt,
+			Key:        txs[i].ID,
+		}
+	})
+
+	return txs[:length], info, nil
 }
 
-func GetTransaction(id string, userId string) (*Transaction, error) {
-	return applyToTx(id, one, userId)
+// UpdateTransaction rewrites a transaction in place, keeping its ID, and moves
+// its balance effect from the old wallets to the new ones, all in one unit of
+// work. A zero date keeps the date the transaction occurred at, the splits
+// replace those the transaction had. The tags in RemoveTags are taken off the
+// transaction before those in AddTags are put on it. A cleared transaction
+// whose amount, wallets or date change is pending again.
+func UpdateTransaction(
+	id string,
+	input TransactionInput,
+	userId string,
+) (*Transaction, *AffectedWallets, error) {
+	var (
+		tx      *Transaction
+		wallets *AffectedWallets
+	)
+
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) (err error) {
+		old, err := applyToTx(uow, id, lock, userId)
+		if err != nil {
+			return
+		}
+		if old.Status == constant.TransactionStatuses().Reconciled {
+			return errTxReconciled
+		}
+
+		if _, err = revertTx(uow, old); err != nil {
+			return
+		}
+
+		if err = input.check(uow, userId); err != nil {
+			return
+		}
+
+		tim := time.Time(input.Date)
+		if tim.IsZero() {
+			tim = old.OccurredAt
+		}
+
+		_tx := Transaction{
+			ID:          id,
+			From:        input.From,
+			To:          input.To,
+			Amount:      input.Amount,
+			DstAmount:   input.DstAmount,
+			Type:        input.Type,
+			Category:    input.Category,
+			Description: input.Description,
+			Status:      old.Status,
+			OccurredAt:  tim,
+			UserId:      userId,
+		}
+		if old.Status == constant.TransactionStatuses().Cleared &&
+			movesBalances(old, &_tx) {
+			_tx.Status = constant.TransactionStatuses().Pending
+		}
+		mapper := orm.NewTxMapper(uow, _tx, input.Type)
+
+		tmp, err := mapper.Update(&_tx)
+		if err != nil {
+			return
+		}
+
+		tx = tmp.(*Transaction)
+		tx.Date = date.Date(tx.OccurredAt)
+
+		if err = deleteSplits(uow, id, userId); err != nil {
+			return
+		}
+		if len(input.Splits) > 0 {
+			if err = insertSplits(uow, tx, input.Splits); err != nil {
+				return
+			}
+		}
+
+		if err = untagTx(uow, tx, input.RemoveTags); err != nil {
+			return
+		}
+		if err = tagTx(uow, tx, input.AddTags); err != nil {
+			return
+		}
+		if err = reloadTags(uow, tx); err != nil {
+			return
+		}
+
+		wallets, err = applyTx(uow, tx)
+		return
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, wallets, nil
 }
 
-func DeleteTransaction(id string, userId string) (*Transaction, error) {
-	return applyToTx(id, delete, userId)
+// movesBalances reports whether rewriting old into tx changes what it does
+// to its wallets or the day it happened on
+func movesBalances(old *Transaction, tx *Transaction) bool {
+	if old.Type != tx.Type || !old.Amount.Equal(tx.Amount) ||
+		old.From != tx.From || old.To != tx.To ||
+		!toDay(old.OccurredAt).Equal(toDay(tx.OccurredAt)) {
+		return true
+	}
+	if tx.Type != constant.TransactionTypes().Transfer {
+		return false
+	}
+	if old.DstAmount == nil || tx.DstAmount == nil {
+		return old.DstAmount != tx.DstAmount
+	}
+	return !old.DstAmount.Equal(*tx.DstAmount)
 }
 
-func ListTransactions(walletName string, userId string) ([]Transaction, error) {
-	txTypes := constant.TransactionTypes()
-	mapper := orm.NewTxMapper(_Transaction{UserId: userId}, txTypes.Expense)
+// TransactionFilter the structure narrows down the transactions listed by
+// ListTransactions, a zero field does not filter anything. A transaction
+// passes Categories when it, or one of its splits, is filed under any of them
+// or a category below them, and Tags when it has any of them. Transactions
+// of one wallet are listed oldest first, those of every wallet newest first.
+type TransactionFilter struct {
+	Wallet      string
+	FromDate    date.Date
+	ToDate      date.Date
+	Categories  []string
+	Types       []constant.TransactionType
+	MinAmount   *decimal.Decimal
+	MaxAmount   *decimal.Decimal
+	Description string
+	Statuses    []constant.TransactionStatus
+	Tags        []string
+}
 
-	_tx := _Transaction{Wallet: walletName, UserId: userId}
+// pass this to ORM when listing transactions
+type _TransactionFilter struct {
+	Wallet      string           `db:"wallet"`
+	FromDate    *time.Time       `db:"from_date"`
+	ToDate      *time.Time       `db:"to_date"`
+	Categories  pq.StringArray   `db:"categories"`
+	Types       pq.StringArray   `db:"types"`
+	MinAmount   *decimal.Decimal `db:"min_amount"`
+	MaxAmount   *decimal.Decimal `db:"max_amount"`
+	Description string           `db:"description"`
+	Statuses    pq.StringArray   `db:"statuses"`
+	Tags        pq.StringArray   `db:"tags"`
+	UserId      string           `db:"user_id"`
+	orm.Page
+}
 
-	tmp, err := mapper.Many(&_tx)
+func ListTransactions(
+	filter TransactionFilter,
+	page Page,
+	userId string,
+) ([]Transaction, *PageInfo, error) {
+	_filter, err := filter.toFilter(userId)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+	_filter.Page = *_page
+
+	txTypes := constant.TransactionTypes()
+	mapper := orm.NewTxMapper(
+		nil,
+		_Transaction{UserId: userId},
+		txTypes.Expense,
+	)
+
+	var tmp interface{}
+	if filter.Wallet == "" {
+		tmp, err = mapper.All(_filter)
+	} else {
+		tmp, err = mapper.Many(_filter)
+	}
+	if err != nil {
+		return nil, nil, err
 	}
 
 	_txs := (*transactions)(tmp.(*[]_Transaction))
-	return _txs.toTransactions(), nil
+	txs := _txs.toTransactions()
+	length, info := page.cut(len(txs), func(i int) cursor {
+		return cursor{
+			OccurredAt: &txs[i].OccurredAt,
+			CreatedAt:  txs[i].CreatedAt,
+			Key:        txs[i].ID,
+		}
+	})
+
+	txs = txs[:length]
+	if err := loadSplits(nil, txs); err != nil {
+		return nil, nil, err
+	}
+	if err := loadTags(nil, txs); err != nil {
+		return nil, nil, err
+	}
+
+	return txs, info, nil
 }
 
+// ClearTransactions removes every transaction of the user, the content of
+// their attachments is removed once they are gone
 func ClearTransactions(userId string) ([]Transaction, error) {
-	txTypes := constant.TransactionTypes()
-	mapper := orm.NewTxMapper(_Transaction{UserId: userId}, txTypes.Expense)
+	var (
+		tmp         interface{}
+		attachments []Attachment
+	)
+
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) (err error) {
+		attachments, err = listUserAttachments(uow, userId)
+		if err != nil {
+			return
+		}
+
+		txTypes := constant.TransactionTypes()
+		mapper := orm.NewTxMapper(
+			uow,
+			_Transaction{UserId: userId},
+			txTypes.Expense,
+		)
 
-	tmp, err := mapper.Clear()
+		tmp, err = mapper.Clear()
+		return
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	removeContents(attachments)
+
 	_txs := (*transactions)(tmp.(*[]_Transaction))
 	return _txs.toTransactions(), nil
 }
 
+// SetTransactionsStatus marks transactions as pending or as cleared, either
+// all of them or none. A reconciled transaction has to be unlocked first.
+func SetTransactionsStatus(
+	ids []string,
+	status constant.TransactionStatus,
+	userId string,
+) error {
+	if status == constant.TransactionStatuses().Reconciled {
+		return errors.New("only a reconciliation reconciles transactions")
+	}
+	if !isTxStatus(status) {
+		return errors.New("unknown transaction status")
+	}
+
+	return inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		return setTxStatus(uow, ids, status, "", userId)
+	})
+}
+
+// UnlockTransaction takes a reconciled transaction back to cleared, so it can
+// be edited or deleted again
+func UnlockTransaction(id string, userId string) (*Transaction, error) {
+	_tx := _Transaction{ID: id, UserId: userId}
+	mapper := orm.NewTxMapper(nil, _tx, constant.TransactionTypes().Expense)
+
+	if _, err := mapper.Unlock(&_tx); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("transaction is not reconciled")
+		}
+		return nil, err
+	}
+
+	return GetTransaction(id, userId)
+}
+
+// pass this to ORM when setting the status of transactions
+type _TxStatus struct {
+	IDs    pq.StringArray             `db:"ids"`
+	Status constant.TransactionStatus `db:"status"`
+	Wallet string                     `db:"wallet"`
+	UserId string                     `db:"user_id"`
+}
+
+// setTxStatus sets the status of transactions as part of uow, each of them
+// must be unreconciled and, if wallet is not empty, affect wallet
+func setTxStatus(
+	uow *orm.UnitOfWork,
+	ids []string,
+	status constant.TransactionStatus,
+	wallet string,
+	userId string,
+) error {
+	unique := make(map[string]bool)
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	_status := _TxStatus{
+		IDs:    pq.StringArray(ids),
+		Status: status,
+		Wallet: wallet,
+		UserId: userId,
+	}
+	mapper := orm.NewTxMapper(uow, _Transaction{}, constant.TransactionTypes().Expense)
+
+	tmp, err := mapper.SetStatus(&_status)
+	if err != nil {
+		return err
+	}
+
+	if len(*(tmp.(*[]_Transaction))) != len(unique) {
+		if wallet == "" {
+			return errors.New("a transaction is missing or reconciled")
+		}
+		return fmt.Errorf(
+			"a transaction is missing, reconciled or not of %s",
+			wallet,
+		)
+	}
+
+	return nil
+}
+
+func insertTx(
+	uow *orm.UnitOfWork,
+	input *TransactionInput,
+	userId string,
+) (*Transaction, error) {
+	tim := time.Time(input.Date)
+	if tim.IsZero() {
+		tim = time.Now()
+	}
+
+	if txTypes := constant.TransactionTypes(); input.Type != txTypes.Transfer {
+		var wallet string
+		switch input.Type {
+		case txTypes.Expense:
+			wallet = input.From
+		case txTypes.Income:
+			wallet = input.To
+		}
+
+		tx, err := createNonTransferTx(uow, input, wallet, tim, userId)
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+
+	tx := Transaction{
+		From:        input.From,
+		To:          input.To,
+		Amount:      input.Amount,
+		DstAmount:   input.DstAmount,
+		Type:        input.Type,
+		Category:    input.Category,
+		Description: input.Description,
+		OccurredAt:  tim,
+		UserId:      userId,
+	}
+
+	mapper := orm.NewTxMapper(uow, tx, input.Type)
+
+	tmp, err := mapper.Insert(&tx)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpTx := tmp.(*Transaction)
+	tmpTx.Date = date.Date(tmpTx.OccurredAt)
+	return tmpTx, nil
+}
+
 func createNonTransferTx(
-	amount decimal.Decimal,
-	t constant.TransactionType,
+	uow *orm.UnitOfWork,
+	input *TransactionInput,
 	wallet string,
-	category string,
-	description string,
 	tim time.Time,
 	userId string,
 ) (*Transaction, error) {
 	tx := _Transaction{
-		Wallet:      wallet,
-		Type:        t,
-		Amount:      amount,
-		Category:    category,
-		Description: description,
-		OccurredAt:  tim,
-		UserId:      userId,
+		Wallet:       wallet,
+		Type:         input.Type,
+		Amount:       input.Amount,
+		WalletAmount: input.Amount,
+		Category:     input.Category,
+		Description:  input.Description,
+		OccurredAt:   tim,
+		UserId:       userId,
 	}
 
-	if t == constant.TransactionTypes().Expense {
+	if input.Type == constant.TransactionTypes().Expense {
 		tx.Role = constant.WalletRoles().SrcWallet
 	} else {
 		tx.Role = constant.WalletRoles().DstWallet
 	}
 
-	mapper := orm.NewTxMapper(tx, t)
+	mapper := orm.NewTxMapper(uow, tx, input.Type)
 
 	tmp, err := mapper.Insert(&tx)
 	if err != nil {
@@ -176,6 +695,295 @@ func createNonTransferTx(
 	return tmpTx.toTransaction(), nil
 }
 
+// check validates the input and fills in what follows from it: the wallets
+// the type of the transaction affects, its category when it is split and the
+// amount a transfer puts into its destination wallet
+func (input *TransactionInput) check(
+	uow *orm.UnitOfWork,
+	userId string,
+) (err error) {
+	input.From, input.To, err = walletsOfTxType(
+		input.Type,
+		input.From,
+		input.To,
+	)
+	if err != nil {
+		return
+	}
+
+	input.Category, err = checkSplits(
+		input.Type,
+		input.Amount,
+		input.Category,
+		input.Splits,
+	)
+	if err != nil {
+		return
+	}
+
+	categories := []string{input.Category}
+	for _, split := range input.Splits {
+		categories = append(categories, split.Category)
+	}
+	if err = checkCategories(uow, categories, input.Type, userId); err != nil {
+		return
+	}
+
+	input.DstAmount, err = input.checkCurrencies(uow, userId)
+	return
+}
+
+// checkCurrencies makes sure the amounts of the input are in the currencies
+// of its wallets, it returns what a transfer puts into its destination
+func (input *TransactionInput) checkCurrencies(
+	uow *orm.UnitOfWork,
+	userId string,
+) (*decimal.Decimal, error) {
+	txTypes := constant.TransactionTypes()
+
+	if input.Type != txTypes.Transfer {
+		if input.DstAmount != nil || input.Rate != nil {
+			return nil, errors.New(
+				"only a transfer takes a destination amount or a rate",
+			)
+		}
+
+		name := input.From
+		if input.Type == txTypes.Income {
+			name = input.To
+		}
+
+		wallet, err := applyToWallet(uow, name, one, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, wallet.checkCurrency(input.Currency)
+	}
+
+	src, err := applyToWallet(uow, input.From, one, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := src.checkCurrency(input.Currency); err != nil {
+		return nil, err
+	}
+
+	dst, err := applyToWallet(uow, input.To, one, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := input.Amount
+	if src.Currency == dst.Currency {
+		if input.DstAmount != nil && !input.DstAmount.Equal(amount) {
+			return nil, errors.New(
+				"a transfer within one currency moves a single amount",
+			)
+		}
+		if input.Rate != nil && !input.Rate.Equal(decimal.New(1, 0)) {
+			return nil, errors.New(
+				"a transfer within one currency takes no rate",
+			)
+		}
+		return &amount, nil
+	}
+
+	switch {
+	case input.DstAmount != nil && input.Rate != nil:
+		return nil, errors.New("give either a destination amount or a rate")
+	case input.DstAmount != nil:
+		if input.DstAmount.Sign() < 0 {
+			return nil, errors.New("destination amount must not be negative")
+		}
+		return input.DstAmount, nil
+	case input.Rate != nil:
+		if input.Rate.Sign() <= 0 {
+			return nil, errors.New("rate must be positive")
+		}
+		dstAmount := amount.Mul(*input.Rate).Round(2)
+		return &dstAmount, nil
+	}
+
+	return nil, fmt.Errorf(
+		"a transfer from %s to %s needs a destination amount or a rate",
+		src.Currency,
+		dst.Currency,
+	)
+}
+
+func (filter *TransactionFilter) toFilter(
+	userId string,
+) (*_TransactionFilter, error) {
+	_filter := _TransactionFilter{
+		Wallet:      filter.Wallet,
+		MinAmount:   filter.MinAmount,
+		MaxAmount:   filter.MaxAmount,
+		Description: filter.Description,
+		UserId:      userId,
+	}
+
+	if from := time.Time(filter.FromDate); !from.IsZero() {
+		_filter.FromDate = &from
+	}
+
+	// the end of the range is inclusive, so it ends when the next day starts
+	if to := time.Time(filter.ToDate); !to.IsZero() {
+		to = to.AddDate(0, 0, 1)
+		_filter.ToDate = &to
+	}
+
+	// a category takes in the transactions of the categories below it
+	if len(filter.Categories) > 0 {
+		categories, err := withSubcategories(filter.Categories, userId)
+		if err != nil {
+			return nil, err
+		}
+		_filter.Categories = categories
+	}
+
+	if len(filter.Statuses) > 0 {
+		_filter.Statuses = make(pq.StringArray, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			if !isTxStatus(status) {
+				return nil, errors.New("unknown transaction status")
+			}
+			_filter.Statuses[i] = string(status)
+		}
+	}
+
+	if len(filter.Tags) > 0 {
+		_filter.Tags = pq.StringArray(filter.Tags)
+	}
+
+	if len(filter.Types) > 0 {
+		_filter.Types = make(pq.StringArray, len(filter.Types))
+		for i, t := range filter.Types {
+			if !isTxType(t) {
+				return nil, errors.New("unknown transaction type")
+			}
+			_filter.Types[i] = string(t)
+		}
+	}
+
+	return &_filter, nil
+}
+
+func isTxStatus(status constant.TransactionStatus) bool {
+	for _, txStatus := range constant.ListTransactionStatuses() {
+		if string(status) == txStatus {
+			return true
+		}
+	}
+	return false
+}
+
+func isTxType(t constant.TransactionType) bool {
+	for _, txType := range constant.ListTransactionTypes() {
+		if string(t) == txType {
+			return true
+		}
+	}
+	return false
+}
+
+// walletsOfTxType keeps only the wallets a transaction of type t affects and
+// makes sure none of them is missing
+func walletsOfTxType(
+	t constant.TransactionType,
+	from string,
+	to string,
+) (string, string, error) {
+	txTypes := constant.TransactionTypes()
+	switch t {
+	case txTypes.Expense:
+		to = ""
+	case txTypes.Income:
+		from = ""
+	case txTypes.Transfer:
+	default:
+		return "", "", errors.New("unknown transaction type")
+	}
+
+	if t != txTypes.Income && from == "" {
+		return "", "", errors.New("source wallet is required")
+	}
+	if t != txTypes.Expense && to == "" {
+		return "", "", errors.New("destination wallet is required")
+	}
+
+	return from, to, nil
+}
+
+// applyTx takes the amount of tx out of its source wallet and puts its
+// destination amount into its destination wallet
+func applyTx(uow *orm.UnitOfWork, tx *Transaction) (*AffectedWallets, error) {
+	return affectWallets(uow, tx, false)
+}
+
+// revertTx undoes applyTx
+func revertTx(uow *orm.UnitOfWork, tx *Transaction) (*AffectedWallets, error) {
+	return affectWallets(uow, tx, true)
+}
+
+func affectWallets(
+	uow *orm.UnitOfWork,
+	tx *Transaction,
+	revert bool,
+) (*AffectedWallets, error) {
+	var wallets AffectedWallets
+
+	type walletEffect struct {
+		wallet **Wallet
+		name   string
+		apply  func(wallet *Wallet) error
+	}
+
+	dstAmount := tx.Amount
+	if tx.DstAmount != nil {
+		dstAmount = *tx.DstAmount
+	}
+
+	expend := func(wallet *Wallet) error {
+		if revert {
+			return wallet.Receive(uow, tx.Amount)
+		}
+		return wallet.Expend(uow, tx.Amount)
+	}
+	receive := func(wallet *Wallet) error {
+		// taking back what a wallet received is no spending, a credit
+		// limit does not stop it
+		if revert {
+			return wallet.shiftBalance(uow, dstAmount.Neg())
+		}
+		return wallet.Receive(uow, dstAmount)
+	}
+
+	effects := make([]walletEffect, 0, 2)
+	if from := tx.From; from != "" {
+		effects = append(effects, walletEffect{&wallets.Src, from, expend})
+	}
+	if to := tx.To; to != "" {
+		effects = append(effects, walletEffect{&wallets.Dst, to, receive})
+	}
+
+	// Wallet rows are locked in the order of their names, so two transfers
+	// going opposite ways between the same wallets cannot deadlock
+	sort.Slice(effects, func(i, j int) bool {
+		return effects[i].name < effects[j].name
+	})
+
+	for _, effect := range effects {
+		wallet := Wallet{Name: effect.name, UserId: tx.UserId}
+		if err := effect.apply(&wallet); err != nil {
+			return nil, err
+		}
+		*effect.wallet = &wallet
+	}
+
+	return &wallets, nil
+}
+
 func (tx *_Transaction) toTransaction() *Transaction {
 	tmpTx := Transaction{
 		ID:          tx.ID,
@@ -183,7 +991,10 @@ func (tx *_Transaction) toTransaction() *Transaction {
 		Type:        tx.Type,
 		Category:    tx.Category,
 		Description: tx.Description,
+		Status:      tx.Status,
 		OccurredAt:  tx.OccurredAt,
+		CreatedAt:   tx.CreatedAt,
+		DeletedAt:   tx.DeletedAt,
 		UserId:      tx.UserId,
 	}
 
@@ -208,10 +1019,14 @@ func (tmpTxs *transactions) toTransactions() []Transaction {
 		_tx := _txs[i]
 		tx := _tx.toTransaction()
 
-		if tx.Type == txTypes.Transfer {
+		// a transfer comes as its source row followed by its destination
+		// row, unless the ledger lost one of them
+		if tx.Type == txTypes.Transfer &&
+			i+1 < length && _txs[i+1].ID == _tx.ID {
 			i++
 			_tx = _txs[i]
 			tx.To = _tx.Wallet
+			tx.DstAmount = &_tx.WalletAmount
 		}
 
 		txs = append(txs, *tx)
@@ -220,9 +1035,14 @@ func (tmpTxs *transactions) toTransactions() []Transaction {
 	return txs
 }
 
-func applyToTx(id string, op operation, userId string) (*Transaction, error) {
+func applyToTx(
+	uow *orm.UnitOfWork,
+	id string,
+	op operation,
+	userId string,
+) (*Transaction, error) {
 	_tx := _Transaction{ID: id, UserId: userId}
-	mapper := orm.NewTxMapper(_tx, constant.TransactionTypes().Expense)
+	mapper := orm.NewTxMapper(uow, _tx, constant.TransactionTypes().Expense)
 
 	var tmp interface{}
 	var err error
@@ -231,6 +1051,10 @@ func applyToTx(id string, op operation, userId string) (*Transaction, error) {
 		tmp, err = mapper.Delete(&_tx)
 	case one:
 		tmp, err = mapper.One(&_tx)
+	case lock:
+		tmp, err = mapper.Lock(&_tx)
+	case restore:
+		tmp, err = mapper.Restore(&_tx)
 	}
 
 	if err != nil {
@@ -246,6 +1070,7 @@ func applyToTx(id string, op operation, userId string) (*Transaction, error) {
 	tx := _txs[0].toTransaction()
 	for i := 1; i < length; i++ {
 		tx.To = _txs[i].Wallet
+		tx.DstAmount = &_txs[i].WalletAmount
 	}
 
 	return tx, nil
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/orm"
+)
+
+// TrashPurge the structure counts what a purge of the trash removed for good
+type TrashPurge struct {
+	Transactions int `json:"transactions"`
+	Wallets      int `json:"wallets"`
+	Categories   int `json:"categories"`
+}
+
+// pass this to ORM when purging the trash
+type _TrashCutoff struct {
+	Before time.Time `db:"before"`
+}
+
+// PurgeTrash removes for good what every user put in the trash before
+// before, all in one unit of work. A wallet or a category stays in the trash
+// as long as a transaction, even one in the trash, refers to it. The content
+// of the attachments of the transactions is removed once they are gone.
+func PurgeTrash(before time.Time) (*TrashPurge, error) {
+	var (
+		purge       TrashPurge
+		attachments []Attachment
+	)
+	cutoff := _TrashCutoff{Before: before}
+
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		tmp, err := orm.NewAttachmentMapper(uow, Attachment{}).Trashed(&cutoff)
+		if err != nil {
+			return err
+		}
+		attachments = *(tmp.(*[]Attachment))
+
+		txMapper := orm.NewTxMapper(
+			uow,
+			_Transaction{},
+			constant.TransactionTypes().Expense,
+		)
+		if tmp, err = txMapper.PurgeTrash(&cutoff); err != nil {
+			return err
+		}
+		purge.Transactions = len(*(tmp.(*[]_Transaction)))
+
+		walletMapper := orm.NewWalletMapper(uow, Wallet{})
+		if tmp, err = walletMapper.PurgeTrash(&cutoff); err != nil {
+			return err
+		}
+		purge.Wallets = len(*(tmp.(*[]Wallet)))
+
+		categoryMapper := orm.NewCategoryMapper(uow, Category{})
+		if tmp, err = categoryMapper.PurgeTrash(&cutoff); err != nil {
+			return err
+		}
+		purge.Categories = len(*(tmp.(*[]Category)))
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	removeContents(attachments)
+	return &purge, nil
+}
@@ -1,30 +1,71 @@
 package model
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/expenseledger/web-service/config"
 	"github.com/expenseledger/web-service/constant"
 	"github.com/expenseledger/web-service/orm"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
-// Wallet the structure represents a wallet in presentation layer
+// Wallet the structure represents a wallet in presentation layer. The balance
+// of a credit wallet is negative by what is owed on it, CreditLimit,
+// StatementDay and DueDay are the terms of the card. OverdraftPolicy tells how
+// far below zero spending may take the balance, down to OverdraftFloor for a
+// FLOOR policy.
 type Wallet struct {
-	Name    string              `json:"name" db:"name"`
-	Type    constant.WalletType `json:"type" db:"type"`
-	Balance decimal.Decimal     `json:"balance" db:"balance"`
-	UserId  string              `json:"userId" db:"user_id"`
+	Name            string                   `json:"name" db:"name"`
+	Type            constant.WalletType      `json:"type" db:"type"`
+	Balance         decimal.Decimal          `json:"balance" db:"balance"`
+	Currency        string                   `json:"currency" db:"currency"`
+	BaseBalance     *decimal.Decimal         `json:"base_balance,omitempty" db:"-"`
+	CreditLimit     *decimal.Decimal         `json:"credit_limit,omitempty" db:"credit_limit"`
+	StatementDay    *int                     `json:"statement_day,omitempty" db:"statement_day"`
+	DueDay          *int                     `json:"due_day,omitempty" db:"due_day"`
+	OverdraftPolicy constant.OverdraftPolicy `json:"overdraft_policy" db:"overdraft_policy"`
+	OverdraftFloor  *decimal.Decimal         `json:"overdraft_floor,omitempty" db:"overdraft_floor"`
+	CreatedAt       time.Time                `json:"-" db:"created_at"`
+	DeletedAt       *time.Time               `json:"deleted_at,omitempty" db:"deleted_at"`
+	UserId          string                   `json:"userId" db:"user_id"`
 }
 
-// CreateWallet inserts wallet to DB
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// CreateWallet inserts wallet to DB, an empty currency stands for the
+// default currency
 func CreateWallet(
 	name string,
 	t constant.WalletType,
 	balance decimal.Decimal,
+	currency string,
 	userId string,
 ) (*Wallet, error) {
-	w := Wallet{Name: name, Type: t, Balance: balance, UserId: userId}
-	mapper := orm.NewWalletMapper(w)
+	if currency == "" {
+		currency = config.GetConfigs().DefaultCurrency
+	}
+	if !currencyCode.MatchString(currency) {
+		return nil, errors.New("currency must be an ISO 4217 code")
+	}
+
+	w := Wallet{
+		Name:     name,
+		Type:     t,
+		Balance:  balance,
+		Currency: currency,
+		UserId:   userId,
+	}
+	mapper := orm.NewWalletMapper(nil, w)
 
 	tmp, err := mapper.Insert(&w)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, errors.New("wallet already exists, it may be in the trash")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -34,17 +75,132 @@ func CreateWallet(
 
 // GetWallet returns matching wallet from DB
 func GetWallet(name string, userId string) (*Wallet, error) {
-	return applyToWallet(name, one, userId)
+	return applyToWallet(nil, name, one, userId)
 }
 
-// DeleteWallet removes wallet from DB
+// DeleteWallet moves wallet to the trash along with its balance, no
+// transaction can be made to or from it until it is restored
 func DeleteWallet(name string, userId string) (*Wallet, error) {
-	return applyToWallet(name, delete, userId)
+	return applyToWallet(nil, name, delete, userId)
+}
+
+// RestoreWallet takes wallet out of the trash
+func RestoreWallet(name string, userId string) (*Wallet, error) {
+	return applyToWallet(nil, name, restore, userId)
+}
+
+// pass this to ORM when renaming a wallet
+type _WalletRename struct {
+	Name    string `db:"name"`
+	NewName string `db:"new_name"`
+	UserId  string `db:"user_id"`
+}
+
+// UpdateWallet renames wallet and changes its type, an empty newName or type
+// leaves it as it is. Renaming carries the history of the wallet over, its
+// transactions, reconciliations, recurring rules and goals refer to the new
+// name afterwards, all in one unit of work. The audit records of ledger repairs
+// keep the name the wallet had at the time.
+func UpdateWallet(
+	name string,
+	newName string,
+	t constant.WalletType,
+	userId string,
+) (*Wallet, error) {
+	if t != "" && !isWalletType(t) {
+		return nil, errors.New("unknown wallet type")
+	}
+
+	var wallet *Wallet
+	err := inUnitOfWork(func(uow *orm.UnitOfWork) error {
+		w, err := applyToWallet(uow, name, one, userId)
+		if err != nil {
+			return err
+		}
+
+		if newName != "" && newName != name {
+			rename := _WalletRename{
+				Name:    name,
+				NewName: newName,
+				UserId:  userId,
+			}
+			mapper := orm.NewWalletMapper(uow, Wallet{})
+
+			tmp, err := mapper.Rename(&rename)
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return errors.New(
+					"wallet already exists, it may be in the trash",
+				)
+			}
+			if err != nil {
+				return err
+			}
+			w = tmp.(*Wallet)
+		}
+
+		if t != "" && t != w.Type {
+			w.Type = t
+			mapper := orm.NewWalletMapper(uow, *w)
+
+			tmp, err := mapper.Update(w)
+			if err != nil {
+				return err
+			}
+			w = tmp.(*Wallet)
+		}
+
+		wallet = w
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
 }
 
-// ListWallets ...
-func ListWallets(userId string) ([]Wallet, error) {
-	return applyToWallets(list, userId)
+// ListWallets returns a page of the wallets of the user
+func ListWallets(page Page, userId string) ([]Wallet, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewWalletMapper(nil, Wallet{})
+
+	tmp, err := mapper.Many(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wallets := *(tmp.(*[]Wallet))
+	length, info := page.cut(len(wallets), func(i int) cursor {
+		return cursor{CreatedAt: wallets[i].CreatedAt, Key: wallets[i].Name}
+	})
+
+	return wallets[:length], info, nil
+}
+
+// ListTrashedWallets returns a page of the wallets of the user in the trash
+func ListTrashedWallets(page Page, userId string) ([]Wallet, *PageInfo, error) {
+	_page, err := page.toPage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mapper := orm.NewWalletMapper(nil, Wallet{})
+
+	tmp, err := mapper.Trash(&_UserPage{UserId: userId, Page: *_page})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wallets := *(tmp.(*[]Wallet))
+	length, info := page.cut(len(wallets), func(i int) cursor {
+		return cursor{CreatedAt: wallets[i].CreatedAt, Key: wallets[i].Name}
+	})
+
+	return wallets[:length], info, nil
 }
 
 // ClearWallets ...
@@ -52,27 +208,172 @@ func ClearWallets(userId string) ([]Wallet, error) {
 	return applyToWallets(clear, userId)
 }
 
-func (wallet *Wallet) Expend(tx *Transaction) error {
-	wallet.Balance = wallet.Balance.Sub(tx.Amount)
-	return wallet.update()
+// pass this to ORM when shifting a balance
+type _BalanceShift struct {
+	Name   string          `db:"name"`
+	UserId string          `db:"user_id"`
+	Amount decimal.Decimal `db:"amount"`
 }
 
-func (wallet *Wallet) Receive(tx *Transaction) error {
-	wallet.Balance = wallet.Balance.Add(tx.Amount)
-	return wallet.update()
+// Expend takes the amount out of the wallet as part of uow. A credit wallet
+// refuses it when it would then owe more than its credit limit, and any
+// wallet when its balance would drop below what its overdraft policy allows.
+// The check and the shift are one statement, so concurrent spending cannot
+// slip past it.
+func (wallet *Wallet) Expend(uow *orm.UnitOfWork, amount decimal.Decimal) error {
+	shift := _BalanceShift{
+		Name:   wallet.Name,
+		UserId: wallet.UserId,
+		Amount: amount.Neg(),
+	}
+	mapper := orm.NewWalletMapper(uow, *wallet)
+
+	tmp, err := mapper.Expend(&shift)
+	if err == sql.ErrNoRows {
+		w, err := applyToWallet(uow, wallet.Name, one, wallet.UserId)
+		if err != nil {
+			return err
+		}
+		return w.refusal(amount)
+	}
+	if err != nil {
+		return err
+	}
+
+	*wallet = *(tmp.(*Wallet))
+	return nil
 }
 
-func (wallet *Wallet) update() error {
-	mapper := orm.NewWalletMapper(*wallet)
-	if _, err := mapper.Update(wallet); err != nil {
+// refusal tells why the wallet refused to have the amount taken out of it
+func (wallet *Wallet) refusal(amount decimal.Decimal) error {
+	codes := constant.ErrorCodes()
+	after := wallet.Balance.Sub(amount)
+
+	limit := wallet.CreditLimit
+	if wallet.Type == constant.WalletTypes().Credit && limit != nil &&
+		after.LessThan(limit.Neg()) {
+		return &CodedError{
+			Code: codes.OverCreditLimit,
+			Message: fmt.Sprintf(
+				"%s would go over its credit limit of %s %s",
+				wallet.Name,
+				limit.StringFixed(2),
+				wallet.Currency,
+			),
+		}
+	}
+
+	floor := decimal.Zero
+	if wallet.OverdraftPolicy == constant.OverdraftPolicies().Floor &&
+		wallet.OverdraftFloor != nil {
+		floor = *wallet.OverdraftFloor
+	}
+	available := decimal.Max(wallet.Balance.Sub(floor), decimal.Zero)
+
+	return &CodedError{
+		Code: codes.InsufficientFunds,
+		Message: fmt.Sprintf(
+			"insufficient funds in %s, %s %s is available",
+			wallet.Name,
+			available.StringFixed(2),
+			wallet.Currency,
+		),
+	}
+}
+
+// pass this to ORM when setting the overdraft policy of a wallet
+type _OverdraftPolicy struct {
+	Name   string                   `db:"name"`
+	Policy constant.OverdraftPolicy `db:"overdraft_policy"`
+	Floor  *decimal.Decimal         `db:"overdraft_floor"`
+	UserId string                   `db:"user_id"`
+}
+
+// SetOverdraftPolicy sets how far spending may take the balance of a wallet,
+// floor is the lowest balance a FLOOR policy allows and is given for that
+// policy only. A balance below the new limit already stops any further
+// spending until enough is received.
+func SetOverdraftPolicy(
+	name string,
+	policy constant.OverdraftPolicy,
+	floor *decimal.Decimal,
+	userId string,
+) (*Wallet, error) {
+	if !isOverdraftPolicy(policy) {
+		return nil, errors.New("unknown overdraft policy")
+	}
+	if policy == constant.OverdraftPolicies().Floor {
+		if floor == nil {
+			return nil, errors.New("a FLOOR policy needs a floor")
+		}
+	} else if floor != nil {
+		return nil, errors.New("only a FLOOR policy has a floor")
+	}
+
+	p := _OverdraftPolicy{
+		Name:   name,
+		Policy: policy,
+		Floor:  floor,
+		UserId: userId,
+	}
+	mapper := orm.NewWalletMapper(nil, Wallet{})
+
+	tmp, err := mapper.SetOverdraft(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmp.(*Wallet), nil
+}
+
+// Receive puts the amount into the wallet as part of uow
+func (wallet *Wallet) Receive(uow *orm.UnitOfWork, amount decimal.Decimal) error {
+	return wallet.shiftBalance(uow, amount)
+}
+
+// checkCurrency makes sure an amount in currency belongs in the wallet, an
+// empty currency stands for the currency of the wallet
+func (wallet *Wallet) checkCurrency(currency string) error {
+	if currency == "" || currency == wallet.Currency {
+		return nil
+	}
+	return fmt.Errorf(
+		"amount must be in %s, the currency of %s",
+		wallet.Currency,
+		wallet.Name,
+	)
+}
+
+// shiftBalance adds amount to the balance stored in DB, rather than writing
+// back a balance computed here, and refreshes the wallet with the result
+func (wallet *Wallet) shiftBalance(
+	uow *orm.UnitOfWork,
+	amount decimal.Decimal,
+) error {
+	shift := _BalanceShift{
+		Name:   wallet.Name,
+		UserId: wallet.UserId,
+		Amount: amount,
+	}
+	mapper := orm.NewWalletMapper(uow, *wallet)
+
+	tmp, err := mapper.ShiftBalance(&shift)
+	if err != nil {
 		return err
 	}
+
+	*wallet = *(tmp.(*Wallet))
 	return nil
 }
 
-func applyToWallet(name string, op operation, userId string) (*Wallet, error) {
+func applyToWallet(
+	uow *orm.UnitOfWork,
+	name string,
+	op operation,
+	userId string,
+) (*Wallet, error) {
 	w := Wallet{Name: name, UserId: userId}
-	mapper := orm.NewWalletMapper(w)
+	mapper := orm.NewWalletMapper(uow, w)
 
 	var tmp interface{}
 	var err error
@@ -81,6 +382,8 @@ func applyToWallet(name string, op operation, userId string) (*Wallet, error) {
 		tmp, err = mapper.Delete(&w)
 	case one:
 		tmp, err = mapper.One(&w)
+	case restore:
+		tmp, err = mapper.Restore(&w)
 	}
 
 	if err != nil {
@@ -92,13 +395,11 @@ func applyToWallet(name string, op operation, userId string) (*Wallet, error) {
 
 func applyToWallets(op operation, userId string) ([]Wallet, error) {
 	wallet := Wallet{UserId: userId}
-	mapper := orm.NewWalletMapper(wallet)
+	mapper := orm.NewWalletMapper(nil, wallet)
 
 	var tmp interface{}
 	var err error
 	switch op {
-	case list:
-		tmp, err = mapper.Many(&wallet)
 	case clear:
 		tmp, err = mapper.Clear()
 	}
@@ -110,3 +411,21 @@ func applyToWallets(op operation, userId string) ([]Wallet, error) {
 	wallets := *(tmp.(*[]Wallet))
 	return wallets, nil
 }
+
+func isWalletType(t constant.WalletType) bool {
+	for _, walletType := range constant.ListWalletTypes() {
+		if string(t) == walletType {
+			return true
+		}
+	}
+	return false
+}
+
+func isOverdraftPolicy(policy constant.OverdraftPolicy) bool {
+	for _, p := range constant.ListOverdraftPolicies() {
+		if string(policy) == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/expenseledger/web-service/constant"
+	"github.com/expenseledger/web-service/db"
+	"github.com/shopspring/decimal"
+)
+
+// needDB skips a test which needs PostgreSQL unless one is configured the
+// way the service connects to it, and makes sure its tables exist
+func needDB(t *testing.T) {
+	if os.Getenv("DB_NAME") == "" && os.Getenv("DATABASE_URL") == "" {
+		t.Skip(
+			"skipped, no PostgreSQL configured: set DB_USER, DB_PASSWORD, " +
+				"DB_NAME and DB_PORT, or MODE=PRODUCTION and DATABASE_URL",
+		)
+	}
+	if err := db.CreateTables(); err != nil {
+		t.Fatal("creating tables:", err)
+	}
+}
+
+// TestConcurrentSpending posts expenses on one wallet from many goroutines
+// at once, along with transfers going opposite ways between two wallets, and
+// checks that no balance shift is lost and that no two of them deadlock
+func TestConcurrentSpending(t *testing.T) {
+	needDB(t)
+
+	const expenses = 20
+	userId := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	defer func() {
+		ClearTransactions(userId)
+		ClearWallets(userId)
+		ClearCategories(userId)
+	}()
+
+	opening := decimal.New(1000, 0)
+	amount := decimal.New(150, -2)
+	transfer := decimal.New(10, 0)
+
+	if _, err := CreateCategory(
+		"Test",
+		constant.CategoryKinds().Any,
+		"",
+		userId,
+	); err != nil {
+		t.Fatal("creating category:", err)
+	}
+	for _, name := range []string{"Alpha", "Beta"} {
+		_, err := CreateWallet(
+			name,
+			constant.WalletTypes().Cash,
+			opening,
+			"",
+			userId,
+		)
+		if err != nil {
+			t.Fatal("creating wallet:", err)
+		}
+	}
+
+	txTypes := constant.TransactionTypes()
+	inputs := make([]TransactionInput, 0, expenses+2)
+	for i := 0; i < expenses; i++ {
+		inputs = append(inputs, TransactionInput{
+			Amount:   amount,
+			Type:     txTypes.Expense,
+			From:     "Alpha",
+			Category: "Test",
+		})
+	}
+	inputs = append(
+		inputs,
+		TransactionInput{
+			Amount:   transfer,
+			Type:     txTypes.Transfer,
+			From:     "Alpha",
+			To:       "Beta",
+			Category: "Test",
+		},
+		TransactionInput{
+			Amount:   transfer,
+			Type:     txTypes.Transfer,
+			From:     "Beta",
+			To:       "Alpha",
+			Category: "Test",
+		},
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(inputs))
+	for _, input := range inputs {
+		wg.Add(1)
+		go func(input TransactionInput) {
+			defer wg.Done()
+			if _, _, err := CreateTransction(input, userId); err != nil {
+				errs <- err
+			}
+		}(input)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error("creating transaction:", err)
+	}
+
+	spent := amount.Mul(decimal.New(expenses, 0))
+	want := map[string]decimal.Decimal{
+		"Alpha": opening.Sub(spent),
+		"Beta":  opening,
+	}
+	for name, balance := range want {
+		wallet, err := GetWallet(name, userId)
+		if err != nil {
+			t.Fatal("getting wallet:", err)
+		}
+		if !wallet.Balance.Equal(balance) {
+			t.Errorf(
+				"balance of %s is %s, want %s",
+				name,
+				wallet.Balance,
+				balance,
+			)
+		}
+	}
+}
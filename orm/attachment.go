@@ -0,0 +1,36 @@
+package orm
+
+// attachmentStatements holds the statements AttachmentMapper has on top of
+// those of BaseMapper
+type attachmentStatements struct {
+	ofUserStmt  string
+	trashedStmt string
+}
+
+type AttachmentMapper struct {
+	BaseMapper
+	*attachmentStatements
+}
+
+// OfUser lists every attachment of the user
+func (mapper *AttachmentMapper) OfUser(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.ofUserStmt,
+		"Error selecting",
+	)
+}
+
+// Trashed lists the attachments of every user whose transactions have been
+// in the trash since before :before
+func (mapper *AttachmentMapper) Trashed(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.trashedStmt,
+		"Error selecting",
+	)
+}
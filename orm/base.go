@@ -5,43 +5,80 @@ import (
 	"reflect"
 	"sync"
 
-	"github.com/expenseledger/web-service/db"
+	"github.com/jmoiron/sqlx"
 )
 
 // BaseMapper ...
 type BaseMapper struct {
+	*statements
+	modelType reflect.Type
+	uow       *UnitOfWork
+}
+
+// statements holds the SQL statements of a mapper, they are shared by every
+// mapper of the same kind
+type statements struct {
 	once       sync.Once
-	modelType  reflect.Type
 	insertStmt string
 	deleteStmt string
 	oneStmt    string
 	updateStmt string
 	manyStmt   string
 	clearStmt  string
+	// the statements of a mapper whose rows are moved to the trash by
+	// deleteStmt rather than removed
+	restoreStmt    string
+	trashStmt      string
+	purgeTrashStmt string
 }
 
 // Insert ...
 func (mapper *BaseMapper) Insert(obj interface{}) (interface{}, error) {
-	return worker(obj, mapper.modelType, mapper.insertStmt, "Error inserting")
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.insertStmt,
+		"Error inserting",
+	)
 }
 
 // Delete ...
 func (mapper *BaseMapper) Delete(obj interface{}) (interface{}, error) {
-	return worker(obj, mapper.modelType, mapper.deleteStmt, "Error deleting")
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.deleteStmt,
+		"Error deleting",
+	)
 }
 
 // One ...
 func (mapper *BaseMapper) One(obj interface{}) (interface{}, error) {
-	return worker(obj, mapper.modelType, mapper.oneStmt, "Error geting")
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.oneStmt,
+		"Error geting",
+	)
 }
 
 func (mapper *BaseMapper) Update(obj interface{}) (interface{}, error) {
-	return worker(obj, mapper.modelType, mapper.updateStmt, "Error geting")
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.updateStmt,
+		"Error updating",
+	)
 }
 
 // Many ...
 func (mapper *BaseMapper) Many(obj interface{}) (interface{}, error) {
 	return sliceWorker(
+		mapper.uow,
 		obj,
 		mapper.modelType,
 		mapper.manyStmt,
@@ -52,6 +89,7 @@ func (mapper *BaseMapper) Many(obj interface{}) (interface{}, error) {
 // Clear ...
 func (mapper *BaseMapper) Clear() (interface{}, error) {
 	return sliceWorker(
+		mapper.uow,
 		struct{}{},
 		mapper.modelType,
 		mapper.clearStmt,
@@ -59,44 +97,86 @@ func (mapper *BaseMapper) Clear() (interface{}, error) {
 	)
 }
 
+// Restore takes a row out of the trash
+func (mapper *BaseMapper) Restore(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.restoreStmt,
+		"Error restoring",
+	)
+}
+
+// Trash lists a page of the rows of a user in the trash
+func (mapper *BaseMapper) Trash(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.trashStmt,
+		"Error selecting",
+	)
+}
+
+// PurgeTrash removes for good the rows of every user which have been in the
+// trash since before :before
+func (mapper *BaseMapper) PurgeTrash(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.purgeTrashStmt,
+		"Error purging",
+	)
+}
+
 func worker(
+	uow *UnitOfWork,
 	obj interface{},
 	t reflect.Type,
 	sqlStmt string,
 	logMsg string,
 ) (interface{}, error) {
-	stmt, err := db.Conn().PrepareNamed(sqlStmt)
-	if err != nil {
-		log.Println(logMsg, err)
-		return nil, err
-	}
-
-	newObj := reflect.New(t).Interface()
-	if err := stmt.Get(newObj, obj); err != nil {
-		log.Println(logMsg, err)
-		return nil, err
-	}
-	return newObj, nil
+	return uow.run(func(tx *sqlx.Tx) (interface{}, error) {
+		stmt, err := tx.PrepareNamed(sqlStmt)
+		if err != nil {
+			log.Println(logMsg, err)
+			return nil, err
+		}
+		defer stmt.Close()
+
+		newObj := reflect.New(t).Interface()
+		if err := stmt.Get(newObj, obj); err != nil {
+			log.Println(logMsg, err)
+			return nil, err
+		}
+		return newObj, nil
+	})
 }
 
 func sliceWorker(
+	uow *UnitOfWork,
 	obj interface{},
 	t reflect.Type,
 	sqlStmt string,
 	logMsg string,
 ) (interface{}, error) {
-	stmt, err := db.Conn().PrepareNamed(sqlStmt)
-	if err != nil {
-		log.Println(logMsg, err)
-		return nil, err
-	}
-
-	sliceType := reflect.SliceOf(t)
-	newSlice := reflect.MakeSlice(sliceType, 0, 0)
-	resultSet := reflect.New(newSlice.Type()).Interface()
-	if err := stmt.Select(resultSet, obj); err != nil {
-		log.Println(logMsg, err)
-		return nil, err
-	}
-	return resultSet, nil
+	return uow.run(func(tx *sqlx.Tx) (interface{}, error) {
+		stmt, err := tx.PrepareNamed(sqlStmt)
+		if err != nil {
+			log.Println(logMsg, err)
+			return nil, err
+		}
+		defer stmt.Close()
+
+		sliceType := reflect.SliceOf(t)
+		newSlice := reflect.MakeSlice(sliceType, 0, 0)
+		resultSet := reflect.New(newSlice.Type()).Interface()
+		if err := stmt.Select(resultSet, obj); err != nil {
+			log.Println(logMsg, err)
+			return nil, err
+		}
+		return resultSet, nil
+	})
 }
@@ -0,0 +1,36 @@
+package orm
+
+// budgetStatements holds the statements BudgetMapper has on top of those of
+// BaseMapper
+type budgetStatements struct {
+	allStmt   string
+	spentStmt string
+}
+
+type BudgetMapper struct {
+	BaseMapper
+	*budgetStatements
+}
+
+// All lists every budget of :user_id, oldest first
+func (mapper *BudgetMapper) All(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.allStmt,
+		"Error selecting",
+	)
+}
+
+// Spent sums up the expenses in :currency which occurred from :from_date
+// until :to_date for each of :categories, along with the categories below it
+func (mapper *BudgetMapper) Spent(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.spentStmt,
+		"Error selecting",
+	)
+}
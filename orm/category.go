@@ -0,0 +1,118 @@
+package orm
+
+// categoryStatements holds the statements CategoryMapper has on top of those
+// of BaseMapper
+type categoryStatements struct {
+	kindStmt        string
+	renameStmt      string
+	moveStmt        string
+	dropStmt        string
+	lockStmt        string
+	ancestorsStmt   string
+	descendantsStmt string
+	totalsStmt      string
+}
+
+type CategoryMapper struct {
+	BaseMapper
+	*categoryStatements
+}
+
+// SetKind sets the kind of category :name to :kind
+func (mapper *CategoryMapper) SetKind(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.kindStmt,
+		"Error updating",
+	)
+}
+
+// Rename adds category :new_name as a copy of category :name, which is locked
+// until the end of the unit of work, so its references can be moved over
+func (mapper *CategoryMapper) Rename(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.renameStmt,
+		"Error inserting",
+	)
+}
+
+// Move points the transactions, splits, recurring rules, subcategories,
+// budgets and envelopes on category :name to category :new_name, and returns
+// how many of each moved
+func (mapper *CategoryMapper) Move(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.moveStmt,
+		"Error updating",
+	)
+}
+
+// Drop removes category :name for good, which only works once nothing
+// refers to it anymore
+func (mapper *CategoryMapper) Drop(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.dropStmt,
+		"Error deleting",
+	)
+}
+
+// Lock locks the categories :names until the end of the unit of work, in
+// order of name so two units of work locking the same ones do not deadlock
+func (mapper *CategoryMapper) Lock(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.lockStmt,
+		"Error locking",
+	)
+}
+
+// Ancestors returns the categories :names and those above them, each with its
+// depth counting from the category it was reached from, up to :max_depth
+// levels above
+func (mapper *CategoryMapper) Ancestors(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.ancestorsStmt,
+		"Error selecting",
+	)
+}
+
+// Descendants returns the categories :names and those below them, trashed
+// ones included, each with its depth counting from the category it was
+// reached from, up to :max_depth levels below
+func (mapper *CategoryMapper) Descendants(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.descendantsStmt,
+		"Error selecting",
+	)
+}
+
+// Totals sums up the transactions of each category of :user_id, or of each of
+// :categories when it is not NULL, along with those of the categories below
+// it, per currency
+func (mapper *CategoryMapper) Totals(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.totalsStmt,
+		"Error selecting",
+	)
+}
@@ -0,0 +1,76 @@
+package orm
+
+// envelopeStatements holds the statements EnvelopeMapper has on top of those
+// of BaseMapper
+type envelopeStatements struct {
+	allStmt         string
+	overlapStmt     string
+	insertEntryStmt string
+	activityStmt    string
+	incomeStmt      string
+}
+
+type EnvelopeMapper struct {
+	BaseMapper
+	*envelopeStatements
+}
+
+// All lists every envelope of :user_id, oldest first
+func (mapper *EnvelopeMapper) All(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.allStmt,
+		"Error selecting",
+	)
+}
+
+// Overlap lists the envelopes of :user_id but envelope :id which share any of
+// :categories
+func (mapper *EnvelopeMapper) Overlap(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.overlapStmt,
+		"Error selecting",
+	)
+}
+
+// InsertEntry puts an amount of money into, or takes it out of, an envelope
+// for a month
+func (mapper *EnvelopeMapper) InsertEntry(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.insertEntryStmt,
+		"Error inserting",
+	)
+}
+
+// Activity sums up, for each envelope of :user_id and each month before
+// :to_date, what was assigned to it, moved into or out of it and spent from
+// it. A month with none of them is left out.
+func (mapper *EnvelopeMapper) Activity(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.activityStmt,
+		"Error selecting",
+	)
+}
+
+// Income sums up the income of :user_id in each currency for each month from
+// :from_date until :to_date
+func (mapper *EnvelopeMapper) Income(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.incomeStmt,
+		"Error selecting",
+	)
+}
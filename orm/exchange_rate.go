@@ -0,0 +1,24 @@
+package orm
+
+// exchangeRateStatements holds the statements ExchangeRateMapper has on top
+// of those of BaseMapper
+type exchangeRateStatements struct {
+	historyStmt string
+}
+
+type ExchangeRateMapper struct {
+	BaseMapper
+	*exchangeRateStatements
+}
+
+// History lists every rate between :src_currency and :dst_currency, either
+// way round, from the earliest to the latest
+func (mapper *ExchangeRateMapper) History(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.historyStmt,
+		"Error selecting",
+	)
+}
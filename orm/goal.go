@@ -0,0 +1,86 @@
+package orm
+
+// goalStatements holds the statements GoalMapper has on top of those of
+// BaseMapper
+type goalStatements struct {
+	allStmt    string
+	linkStmt   string
+	unlinkStmt string
+	linksStmt  string
+	sharedStmt string
+	savedStmt  string
+}
+
+type GoalMapper struct {
+	BaseMapper
+	*goalStatements
+}
+
+// All lists every goal of :user_id, oldest first
+func (mapper *GoalMapper) All(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.allStmt,
+		"Error selecting",
+	)
+}
+
+// Link counts :share percent of the balance of :wallet toward goal :goal_id
+func (mapper *GoalMapper) Link(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.linkStmt,
+		"Error inserting",
+	)
+}
+
+// Unlink removes every wallet from goal :id
+func (mapper *GoalMapper) Unlink(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.unlinkStmt,
+		"Error deleting",
+	)
+}
+
+// Links lists the wallets linked to each of goals :ids
+func (mapper *GoalMapper) Links(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.linksStmt,
+		"Error selecting",
+	)
+}
+
+// Shared sums up, for each of :wallets, the share of it the goals of
+// :user_id but goal :id already count
+func (mapper *GoalMapper) Shared(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.sharedStmt,
+		"Error selecting",
+	)
+}
+
+// Saved returns the balance of each wallet linked to goal :id, which is not
+// in the trash, along with the net amount which went into it from :from_date
+// until :to_date
+func (mapper *GoalMapper) Saved(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.savedStmt,
+		"Error selecting",
+	)
+}
@@ -0,0 +1,64 @@
+package orm
+
+// ledgerStatements holds the statements LedgerMapper has on top of those of
+// BaseMapper, which keep the audit record of the repairs
+type ledgerStatements struct {
+	balancesStmt   string
+	brokenStmt     string
+	lockWalletStmt string
+	rebalanceStmt  string
+}
+
+type LedgerMapper struct {
+	BaseMapper
+	*ledgerStatements
+}
+
+// Balances returns the stored balance of each wallet of :user_id, or of every
+// user when it is empty, along with the balance its opening balance and its
+// postings add up to
+func (mapper *LedgerMapper) Balances(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.balancesStmt,
+		"Error selecting",
+	)
+}
+
+// Broken returns the transactions of :user_id, or of every user when it is
+// empty, which lack a posting their type calls for or have one too many
+func (mapper *LedgerMapper) Broken(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.brokenStmt,
+		"Error selecting",
+	)
+}
+
+// LockWallet holds a wallet until the unit of work ends, so no posting to it
+// can come in while it is rebalanced
+func (mapper *LedgerMapper) LockWallet(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.lockWalletStmt,
+		"Error locking",
+	)
+}
+
+// Rebalance sets the balance of a wallet to what its opening balance and its
+// postings add up to
+func (mapper *LedgerMapper) Rebalance(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.rebalanceStmt,
+		"Error updating",
+	)
+}
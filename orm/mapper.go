@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"fmt"
 	"reflect"
 
 	"github.com/expenseledger/web-service/constant"
@@ -13,196 +14,2065 @@ type Mapper interface {
 	Update(obj interface{}) (interface{}, error)
 	Many(obj interface{}) (interface{}, error)
 	Clear() (interface{}, error)
+	Restore(obj interface{}) (interface{}, error)
+	Trash(obj interface{}) (interface{}, error)
+	PurgeTrash(obj interface{}) (interface{}, error)
 }
 
+// txFilterCond narrows down the transactions t listed by TxMapper, each of
+// its parameters filters nothing when NULL or empty
+const txFilterCond = `
+	AND (
+		CAST(:from_date AS timestamp with time zone) IS NULL
+		OR t.occurred_at >= :from_date
+	) AND (
+		CAST(:to_date AS timestamp with time zone) IS NULL
+		OR t.occurred_at < :to_date
+	) AND (
+		CAST(:categories AS text[]) IS NULL
+		OR t.category = ANY (CAST(:categories AS text[]))
+		OR EXISTS (
+			SELECT 1
+			FROM transaction_split s
+			WHERE s.transaction_id = t.id
+			AND s.category = ANY (CAST(:categories AS text[]))
+		)
+	) AND (
+		CAST(:types AS transaction_type[]) IS NULL
+		OR t.type = ANY (CAST(:types AS transaction_type[]))
+	) AND (
+		CAST(:min_amount AS numeric) IS NULL
+		OR t.amount >= :min_amount
+	) AND (
+		CAST(:max_amount AS numeric) IS NULL
+		OR t.amount <= :max_amount
+	) AND (
+		CAST(:description AS text) = ''
+		OR strpos(lower(t.description), lower(:description)) > 0
+	) AND (
+		CAST(:statuses AS transaction_status[]) IS NULL
+		OR t.status = ANY (CAST(:statuses AS transaction_status[]))
+	) AND (
+		CAST(:tags AS text[]) IS NULL
+		OR EXISTS (
+			SELECT 1
+			FROM transaction_tag g
+			WHERE g.transaction_id = t.id
+			AND g.tag = ANY (CAST(:tags AS text[]))
+		)
+	)
+`
+
 var (
-	categoryMapper BaseMapper
-	walletMapper   BaseMapper
-	txMapper       TxMapper
+	categoryStmts struct {
+		statements
+		categoryStatements
+	}
+	walletStmts struct {
+		statements
+		walletStatements
+	}
+	splitStmts statements
+	txStmts    struct {
+		statements
+		transactionStatements
+	}
+	recurringStmts struct {
+		statements
+		recurringStatements
+	}
+	exchangeRateStmts struct {
+		statements
+		exchangeRateStatements
+	}
+	tagStmts struct {
+		statements
+		tagStatements
+	}
+	attachmentStmts struct {
+		statements
+		attachmentStatements
+	}
+	reconciliationStmts struct {
+		statements
+		reconciliationStatements
+	}
+	ledgerStmts struct {
+		statements
+		ledgerStatements
+	}
+	budgetStmts struct {
+		statements
+		budgetStatements
+	}
+	envelopeStmts struct {
+		statements
+		envelopeStatements
+	}
+	goalStmts struct {
+		statements
+		goalStatements
+	}
 )
 
-func NewCategoryMapper(model interface{}) Mapper {
-	categoryMapper.once.Do(func() {
-		categoryMapper.insertStmt = `
-			INSERT INTO category (name, user_id)
-			VALUES (:name, :user_id)
-			RETURNING name, user_id;
+// exchangeRateColumns are the columns returned by ExchangeRateMapper
+const exchangeRateColumns = `
+	src_currency, dst_currency, effective_on, rate, created_at, user_id
+`
+
+// exchangeRateKey orders the rates listed by ExchangeRateMapper, it is unique
+// for the rates of a user
+const exchangeRateKey = `
+	src_currency || '/' || dst_currency || '/' ||
+	to_char(effective_on, 'YYYY-MM-DD')
+`
+
+// attachmentColumns are the columns returned by AttachmentMapper
+const attachmentColumns = `
+	id, transaction_id, file_name, content_type, size, created_at, user_id
+`
+
+// reconciliationColumns are the columns returned by ReconciliationMapper
+const reconciliationColumns = `
+	id, wallet, statement_date, statement_balance, reconciled_count,
+	finished_at, created_at, user_id
+`
+
+// reconciliationCutoff is when the statement of reconciliation r ends, the
+// statement date is inclusive
+const reconciliationCutoff = `
+	CAST(r.statement_date + 1 AS timestamp) AT TIME ZONE 'UTC'
+`
+
+// walletEffects lists what each posting of a transaction which is not in the
+// trash does to the balance of its wallet, along with the transaction, its
+// status and its times
+const walletEffects = `
+	SELECT
+	a.wallet, a.user_id, t.id AS transaction_id, t.status, t.occurred_at,
+	t.created_at,
+	CASE WHEN a.role = 'SRC_WALLET'
+		THEN -COALESCE(a.amount, t.amount)
+		ELSE COALESCE(a.amount, t.amount)
+	END AS effect
+	FROM affected_wallet a, transaction t
+	WHERE t.id = a.transaction_id AND t.user_id = a.user_id
+	AND t.deleted_at IS NULL
+`
+
+// ledgerRepairColumns are the columns returned by LedgerMapper for a repair
+const ledgerRepairColumns = `
+	id, kind, wallet, transaction_id, old_balance, new_balance, detail,
+	created_at, user_id
+`
+
+// budgetColumns are the columns returned by BudgetMapper for a budget
+const budgetColumns = `
+	id, name, categories, period, amount, currency, created_at, user_id
+`
+
+// envelopeColumns are the columns returned by EnvelopeMapper for an envelope
+const envelopeColumns = `
+	id, name, categories, currency, start_month, created_at, user_id
+`
+
+// envelopeEntryColumns are the columns returned by EnvelopeMapper for an
+// entry
+const envelopeEntryColumns = `
+	id, envelope_id, month, kind, amount, note, created_at, user_id
+`
+
+// goalColumns are the columns returned by GoalMapper for a goal
+const goalColumns = `
+	id, name, target_amount, target_date, currency, created_at, user_id
+`
+
+// goalWalletColumns are the columns returned by GoalMapper for a wallet
+// linked to a goal
+const goalWalletColumns = `
+	goal_id, wallet, share, user_id
+`
+
+// recurringColumns are the columns returned by RecurringMapper for a rule
+const recurringColumns = `
+	id, type, amount, dst_amount, rate, src_wallet, dst_wallet, category,
+	description,
+	frequency, repeat_every, day_of_month, start_date, end_date,
+	max_occurrences, occurrences, next_date, last_date, last_error, failed_at,
+	created_at, user_id
+`
+
+func NewCategoryMapper(uow *UnitOfWork, model interface{}) *CategoryMapper {
+	categoryStmts.once.Do(func() {
+		categoryStmts.insertStmt = `
+			INSERT INTO category (name, kind, parent, user_id)
+			VALUES (:name, :kind, :parent, :user_id)
+			RETURNING name, kind, parent, created_at, user_id;
 		`
-		categoryMapper.deleteStmt = `
-			DELETE FROM category
+		categoryStmts.deleteStmt = `
+			UPDATE category
+			SET deleted_at = CURRENT_TIMESTAMP
 			WHERE name=:name
 			AND user_id=:user_id
-			RETURNING name, user_id;
+			AND deleted_at IS NULL
+			RETURNING name, kind, parent, deleted_at, user_id;
 		`
-		categoryMapper.oneStmt = `
-			SELECT name, user_id
-			FROM category
+		categoryStmts.restoreStmt = `
+			UPDATE category
+			SET deleted_at = NULL
 			WHERE name=:name
-			AND user_id=:user_id;
+			AND user_id=:user_id
+			AND deleted_at IS NOT NULL
+			RETURNING name, kind, parent, user_id;
+		`
+		categoryStmts.purgeTrashStmt = `
+			DELETE FROM category c
+			WHERE c.deleted_at < :before
+			AND NOT EXISTS (
+				SELECT 1
+				FROM transaction t
+				WHERE t.category = c.name AND t.user_id = c.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM transaction_split s
+				WHERE s.category = c.name AND s.user_id = c.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM recurring_rule r
+				WHERE r.category = c.name AND r.user_id = c.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM category sub
+				WHERE sub.parent = c.name AND sub.user_id = c.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM budget b
+				WHERE c.name = ANY (b.categories) AND b.user_id = c.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM envelope e
+				WHERE c.name = ANY (e.categories) AND e.user_id = c.user_id
+			)
+			RETURNING name, kind, parent, deleted_at, user_id;
+		`
+		categoryStmts.trashStmt = `
+			SELECT name, kind, parent, created_at, deleted_at, user_id
+			FROM category
+			WHERE user_id=:user_id
+			AND deleted_at IS NOT NULL
+			AND (
+				CAST(:after_key AS text) IS NULL
+				OR (created_at, name) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS text)
+				)
+			)
+			ORDER BY created_at ASC, name ASC
+			LIMIT :limit;
 		`
-		categoryMapper.manyStmt = `
-			SELECT name, user_id
+		categoryStmts.oneStmt = `
+			SELECT name, kind, parent, user_id
 			FROM category
-			WHERE user_id=:user_id;
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL;
+		`
+		categoryStmts.updateStmt = `
+			UPDATE category
+			SET parent = :parent
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL
+			RETURNING name, kind, parent, created_at, user_id;
+		`
+		categoryStmts.kindStmt = `
+			UPDATE category
+			SET kind = :kind
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL
+			RETURNING name, kind, parent, created_at, user_id;
+		`
+		// a category whose parent is in the trash is listed at the top
+		categoryStmts.manyStmt = `
+			SELECT c.name, c.kind, c.parent, c.created_at, c.user_id
+			FROM category c
+			WHERE c.user_id=:user_id
+			AND c.deleted_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1
+				FROM category p
+				WHERE p.name = c.parent AND p.user_id = c.user_id
+				AND p.deleted_at IS NULL
+			)
+			AND (
+				CAST(:after_key AS text) IS NULL
+				OR (c.created_at, c.name) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS text)
+				)
+			)
+			ORDER BY c.created_at ASC, c.name ASC
+			LIMIT :limit;
 		`
-		categoryMapper.clearStmt = `
+		categoryStmts.clearStmt = `
 			DELETE FROM category
 			WHERE user_id=:user_id
-			RETURNING name, user_id;
+			RETURNING name, kind, parent, user_id;
+		`
+		categoryStmts.renameStmt = `
+			INSERT INTO category (name, kind, parent, created_at, user_id)
+			SELECT :new_name, kind, parent, created_at, user_id
+			FROM category
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL
+			FOR UPDATE
+			RETURNING name, kind, parent, user_id;
+		`
+		categoryStmts.moveStmt = `
+			WITH txs AS (
+				UPDATE transaction
+				SET category = :new_name
+				WHERE category = :name
+				AND user_id = :user_id
+				RETURNING id
+			), splits AS (
+				UPDATE transaction_split
+				SET category = :new_name
+				WHERE category = :name
+				AND user_id = :user_id
+				RETURNING transaction_id
+			), rules AS (
+				UPDATE recurring_rule
+				SET category = :new_name
+				WHERE category = :name
+				AND user_id = :user_id
+				RETURNING id
+			), subcategories AS (
+				UPDATE category
+				SET parent = :new_name
+				WHERE parent = :name
+				AND user_id = :user_id
+				RETURNING name
+			), budgets AS (
+				UPDATE budget
+				SET categories = ARRAY(
+					SELECT DISTINCT unnest(array_replace(
+						categories,
+						CAST(:name AS text),
+						CAST(:new_name AS text)
+					))
+				)
+				WHERE CAST(:name AS text) = ANY (categories)
+				AND user_id = :user_id
+				RETURNING id
+			), envelopes AS (
+				UPDATE envelope
+				SET categories = ARRAY(
+					SELECT DISTINCT unnest(array_replace(
+						categories,
+						CAST(:name AS text),
+						CAST(:new_name AS text)
+					))
+				)
+				WHERE CAST(:name AS text) = ANY (categories)
+				AND user_id = :user_id
+				RETURNING id
+			)
+			SELECT
+			CAST(:name AS text) AS name,
+			CAST(:new_name AS text) AS new_name,
+			(SELECT COUNT(*) FROM txs) AS transactions,
+			(SELECT COUNT(*) FROM splits) AS splits,
+			(SELECT COUNT(*) FROM rules) AS recurring_rules,
+			(SELECT COUNT(*) FROM subcategories) AS subcategories,
+			(SELECT COUNT(*) FROM budgets) AS budgets,
+			(SELECT COUNT(*) FROM envelopes) AS envelopes,
+			CAST(:user_id AS text) AS user_id;
+		`
+		categoryStmts.dropStmt = `
+			DELETE FROM category
+			WHERE name=:name
+			AND user_id=:user_id
+			RETURNING name, kind, parent, user_id;
+		`
+		categoryStmts.lockStmt = `
+			SELECT name, parent, user_id
+			FROM category
+			WHERE name = ANY (CAST(:names AS text[]))
+			AND user_id=:user_id
+			ORDER BY name ASC
+			FOR UPDATE;
+		`
+		categoryStmts.ancestorsStmt = `
+			WITH RECURSIVE chain AS (
+				SELECT name, parent, user_id, 1 AS depth
+				FROM category
+				WHERE name = ANY (CAST(:names AS text[]))
+				AND user_id=:user_id
+				UNION ALL
+				SELECT c.name, c.parent, c.user_id, chain.depth + 1
+				FROM category c, chain
+				WHERE c.name = chain.parent AND c.user_id = chain.user_id
+				AND chain.depth <= :max_depth
+			)
+			SELECT name, parent, user_id, depth
+			FROM chain
+			ORDER BY depth ASC;
+		`
+		categoryStmts.descendantsStmt = `
+			WITH RECURSIVE tree AS (
+				SELECT
+				name, kind, parent, created_at, deleted_at, user_id, 1 AS depth
+				FROM category
+				WHERE name = ANY (CAST(:names AS text[]))
+				AND user_id=:user_id
+				UNION ALL
+				SELECT
+				c.name, c.kind, c.parent, c.created_at, c.deleted_at, c.user_id,
+				tree.depth + 1
+				FROM category c, tree
+				WHERE c.parent = tree.name AND c.user_id = tree.user_id
+				AND tree.depth <= :max_depth
+			)
+			SELECT name, kind, parent, created_at, deleted_at, user_id, depth
+			FROM tree
+			ORDER BY depth ASC, created_at ASC, name ASC;
+		`
+		// a transaction counts in its category and every category above it,
+		// a split one with the amount of each split in the category of the
+		// split instead
+		categoryStmts.totalsStmt = `
+			WITH RECURSIVE tree AS (
+				SELECT name AS category, name AS member, user_id, 1 AS depth
+				FROM category
+				WHERE user_id = :user_id
+				AND deleted_at IS NULL
+				AND (
+					CAST(:categories AS text[]) IS NULL
+					OR name = ANY (CAST(:categories AS text[]))
+				)
+				UNION ALL
+				SELECT tree.category, c.name, c.user_id, tree.depth + 1
+				FROM category c, tree
+				WHERE c.parent = tree.member AND c.user_id = tree.user_id
+				AND tree.depth <= :max_depth
+			), lines AS (
+				SELECT
+				t.id, t.type, w.currency,
+				COALESCE(s.category, t.category) AS category,
+				COALESCE(s.amount, t.amount) AS amount
+				FROM transaction t
+				JOIN affected_wallet a
+				ON a.transaction_id = t.id AND a.user_id = t.user_id
+				AND a.role = CASE
+					WHEN t.type = 'INCOME' THEN CAST('DST_WALLET' AS wallet_role)
+					ELSE CAST('SRC_WALLET' AS wallet_role)
+				END
+				JOIN wallet w ON w.name = a.wallet AND w.user_id = a.user_id
+				LEFT JOIN transaction_split s
+				ON s.transaction_id = t.id AND s.user_id = t.user_id
+				WHERE t.user_id = :user_id
+				AND t.deleted_at IS NULL
+				AND (
+					CAST(:from_date AS timestamp with time zone) IS NULL
+					OR t.occurred_at >= :from_date
+				) AND (
+					CAST(:to_date AS timestamp with time zone) IS NULL
+					OR t.occurred_at < :to_date
+				)
+			)
+			SELECT
+			tree.category, l.currency, COUNT(DISTINCT l.id) AS count,
+			COALESCE(SUM(l.amount) FILTER (WHERE l.type = 'EXPENSE'), 0)
+			AS expense,
+			COALESCE(SUM(l.amount) FILTER (WHERE l.type = 'INCOME'), 0)
+			AS income,
+			COALESCE(SUM(l.amount) FILTER (WHERE l.type = 'TRANSFER'), 0)
+			AS transfer
+			FROM tree, lines l
+			WHERE l.category = tree.member
+			GROUP BY tree.category, l.currency
+			ORDER BY tree.category ASC, l.currency ASC;
 		`
 	})
 
-	categoryMapper.modelType = reflect.TypeOf(model)
-
-	return &categoryMapper
+	return &CategoryMapper{
+		BaseMapper: BaseMapper{
+			statements: &categoryStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		categoryStatements: &categoryStmts.categoryStatements,
+	}
 }
 
-func NewWalletMapper(model interface{}) Mapper {
-	walletMapper.once.Do(func() {
-		walletMapper.insertStmt = `
-			INSERT INTO wallet (name, type, balance, user_id)
-			VALUES (:name, :type, :balance, :user_id)
-			RETURNING name, type, balance, user_id;
+func NewWalletMapper(uow *UnitOfWork, model interface{}) *WalletMapper {
+	walletStmts.once.Do(func() {
+		walletStmts.insertStmt = `
+			INSERT INTO wallet
+			(name, type, balance, opening_balance, currency, user_id)
+			VALUES (:name, :type, :balance, :balance, :currency, :user_id)
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
 		`
-		walletMapper.deleteStmt = `
-			DELETE FROM wallet
+		walletStmts.deleteStmt = `
+			UPDATE wallet
+			SET deleted_at = CURRENT_TIMESTAMP
 			WHERE name=:name
 			AND user_id=:user_id
-			RETURNING name, type, balance, user_id;
+			AND deleted_at IS NULL
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, deleted_at, user_id;
 		`
-		walletMapper.oneStmt = `
-			SELECT name, type, balance, user_id
+		walletStmts.restoreStmt = `
+			UPDATE wallet
+			SET deleted_at = NULL
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NOT NULL
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
+		`
+		walletStmts.purgeTrashStmt = `
+			DELETE FROM wallet w
+			WHERE w.deleted_at < :before
+			AND NOT EXISTS (
+				SELECT 1
+				FROM affected_wallet a
+				WHERE a.wallet = w.name AND a.user_id = w.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM goal_wallet g
+				WHERE g.wallet = w.name AND g.user_id = w.user_id
+			)
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, deleted_at, user_id;
+		`
+		walletStmts.trashStmt = `
+			SELECT name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, created_at, deleted_at,
+			user_id
+			FROM wallet
+			WHERE user_id=:user_id
+			AND deleted_at IS NOT NULL
+			AND (
+				CAST(:after_key AS text) IS NULL
+				OR (created_at, name) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS text)
+				)
+			)
+			ORDER BY created_at ASC, name ASC
+			LIMIT :limit;
+		`
+		walletStmts.oneStmt = `
+			SELECT name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id
+			FROM wallet
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL;
+		`
+		walletStmts.findStmt = `
+			SELECT name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, deleted_at, user_id
 			FROM wallet
 			WHERE name=:name
 			AND user_id=:user_id;
 		`
-		walletMapper.updateStmt = `
+		walletStmts.updateStmt = `
+			UPDATE wallet
+			SET type = :type
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
+		`
+		walletStmts.renameStmt = `
+			WITH wallet_row AS (
+				INSERT INTO wallet
+				(name, type, balance, opening_balance, currency, credit_limit,
+				statement_day, due_day, overdraft_policy, overdraft_floor,
+				created_at, user_id)
+				SELECT
+				:new_name, type, balance, opening_balance, currency, credit_limit,
+				statement_day, due_day, overdraft_policy, overdraft_floor,
+				created_at, user_id
+				FROM wallet
+				WHERE name=:name
+				AND user_id=:user_id
+				AND deleted_at IS NULL
+				FOR UPDATE
+				RETURNING
+				name, type, balance, currency, credit_limit, statement_day,
+				due_day, overdraft_policy, overdraft_floor, user_id
+			), postings AS (
+				UPDATE affected_wallet
+				SET wallet = :new_name
+				WHERE wallet = :name
+				AND user_id = :user_id
+				AND EXISTS (SELECT 1 FROM wallet_row)
+			), reconciliations AS (
+				UPDATE reconciliation
+				SET wallet = :new_name
+				WHERE wallet = :name
+				AND user_id = :user_id
+				AND EXISTS (SELECT 1 FROM wallet_row)
+			), rules AS (
+				UPDATE recurring_rule
+				SET
+				src_wallet = CASE WHEN src_wallet = :name
+					THEN :new_name ELSE src_wallet END,
+				dst_wallet = CASE WHEN dst_wallet = :name
+					THEN :new_name ELSE dst_wallet END
+				WHERE (src_wallet = :name OR dst_wallet = :name)
+				AND user_id = :user_id
+				AND EXISTS (SELECT 1 FROM wallet_row)
+			), goals AS (
+				UPDATE goal_wallet
+				SET wallet = :new_name
+				WHERE wallet = :name
+				AND user_id = :user_id
+				AND EXISTS (SELECT 1 FROM wallet_row)
+			)
+			SELECT name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id
+			FROM wallet_row;
+		`
+		walletStmts.dropStmt = `
+			DELETE FROM wallet
+			WHERE name=:name
+			AND user_id=:user_id
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
+		`
+		walletStmts.shiftBalanceStmt = `
+			UPDATE wallet
+			SET balance = balance + :amount
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
+		`
+		// a credit wallet with a limit may not owe more than the limit, and
+		// no wallet may go lower than its overdraft policy lets it
+		walletStmts.expendStmt = `
+			UPDATE wallet
+			SET balance = balance + :amount
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL
+			AND (
+				type <> 'CREDIT'
+				OR credit_limit IS NULL
+				OR balance + :amount >= -credit_limit
+			) AND (
+				overdraft_policy = 'ALLOW'
+				OR overdraft_policy = 'DISALLOW' AND balance + :amount >= 0
+				OR overdraft_policy = 'FLOOR'
+				AND balance + :amount >= overdraft_floor
+			)
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
+		`
+		walletStmts.creditStmt = `
 			UPDATE wallet
-			SET balance=:balance
+			SET
+			credit_limit = :credit_limit, statement_day = :statement_day,
+			due_day = :due_day
 			WHERE name=:name
 			AND user_id=:user_id
-			RETURNING name, type, balance, user_id;
+			AND deleted_at IS NULL
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
 		`
-		walletMapper.manyStmt = `
-			SELECT name, type, balance, user_id 
+		walletStmts.overdraftStmt = `
+			UPDATE wallet
+			SET
+			overdraft_policy = :overdraft_policy,
+			overdraft_floor = :overdraft_floor
+			WHERE name=:name
+			AND user_id=:user_id
+			AND deleted_at IS NULL
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
+		`
+		walletStmts.paymentsStmt = `
+			SELECT COALESCE(SUM(COALESCE(a.amount, t.amount)), 0) AS payments
+			FROM affected_wallet a, transaction t
+			WHERE a.wallet=:name
+			AND a.user_id=:user_id
+			AND a.role = 'DST_WALLET'
+			AND t.id = a.transaction_id AND t.user_id = a.user_id
+			AND t.type = 'TRANSFER'
+			AND t.deleted_at IS NULL
+			AND t.occurred_at >= :from_date
+			AND t.occurred_at < :to_date;
+		`
+		walletStmts.balancesAtStmt = fmt.Sprintf(`
+			SELECT
+			w.name AS wallet, w.currency, d.day AS date,
+			w.balance - COALESCE((
+				SELECT SUM(e.effect)
+				FROM (%s) e
+				WHERE e.wallet = w.name
+				AND e.user_id = w.user_id
+				AND e.occurred_at >=
+				CAST(d.day + 1 AS timestamp) AT TIME ZONE 'UTC'
+			), 0) AS balance,
+			w.user_id
+			FROM wallet w, unnest(CAST(:dates AS date[])) AS d(day)
+			WHERE w.user_id=:user_id
+			AND w.deleted_at IS NULL
+			AND (CAST(:name AS text) = '' OR w.name = :name)
+			ORDER BY w.created_at ASC, w.name ASC, d.day ASC;
+		`, walletEffects)
+		walletStmts.runningBalancesStmt = fmt.Sprintf(`
+			SELECT id, running_balance
+			FROM (
+				SELECT
+				e.transaction_id AS id,
+				w.balance - COALESCE(SUM(e.effect) OVER (
+					ORDER BY
+					e.occurred_at DESC, e.created_at DESC,
+					e.transaction_id DESC
+					ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+				), 0) AS running_balance
+				FROM wallet w
+				JOIN (%s) e
+				ON e.wallet = w.name AND e.user_id = w.user_id
+				WHERE w.name=:name
+				AND w.user_id=:user_id
+			) r
+			WHERE id = ANY (CAST(:ids AS uuid[]));
+		`, walletEffects)
+		walletStmts.manyStmt = `
+			SELECT name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, created_at, user_id
 			FROM wallet
-			WHERE user_id=:user_id;
+			WHERE user_id=:user_id
+			AND deleted_at IS NULL
+			AND (
+				CAST(:after_key AS text) IS NULL
+				OR (created_at, name) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS text)
+				)
+			)
+			ORDER BY created_at ASC, name ASC
+			LIMIT :limit;
 		`
-		walletMapper.clearStmt = `
+		walletStmts.clearStmt = `
 			DELETE FROM wallet
 			WHERE user_id=:user_id
-			RETURNING name, type, balance, user_id;
+			RETURNING name, type, balance, currency, credit_limit, statement_day,
+			due_day, overdraft_policy, overdraft_floor, user_id;
 		`
 	})
 
-	walletMapper.modelType = reflect.TypeOf(model)
+	return &WalletMapper{
+		BaseMapper: BaseMapper{
+			statements: &walletStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		walletStatements: &walletStmts.walletStatements,
+	}
+}
+
+func NewSplitMapper(uow *UnitOfWork, model interface{}) *SplitMapper {
+	splitStmts.once.Do(func() {
+		splitStmts.insertStmt = `
+			INSERT INTO transaction_split
+			(transaction_id, line, category, amount, note, user_id)
+			VALUES
+			(:transaction_id, :line, :category, :amount, :note, :user_id)
+			RETURNING transaction_id, line, category, amount, note, user_id;
+		`
+		splitStmts.deleteStmt = `
+			DELETE FROM transaction_split
+			WHERE transaction_id = :transaction_id
+			AND user_id = :user_id
+			RETURNING transaction_id, line, category, amount, note, user_id;
+		`
+		splitStmts.manyStmt = `
+			SELECT transaction_id, line, category, amount, note, user_id
+			FROM transaction_split
+			WHERE transaction_id = ANY (CAST(:transaction_ids AS uuid[]))
+			AND user_id = :user_id
+			ORDER BY transaction_id ASC, line ASC;
+		`
+	})
 
-	return &walletMapper
+	return &SplitMapper{
+		BaseMapper: BaseMapper{
+			statements: &splitStmts,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+	}
 }
 
-func NewTxMapper(model interface{}, txType constant.TransactionType) Mapper {
-	txMapper.once.Do(func() {
-		txMapper.insertStmt = `
+func NewTxMapper(
+	uow *UnitOfWork,
+	model interface{},
+	txType constant.TransactionType,
+) *TxMapper {
+	txStmts.once.Do(func() {
+		txStmts.insertStmt = `
 			WITH tx AS (
 				INSERT INTO transaction
 				(amount, type, category, description, occurred_at, user_id)
 				VALUES
 				(:amount, :type, :category, :description, :occurred_at, :user_id)
-				RETURNING id, amount, type, category, description, occurred_at
+				RETURNING
+				id, amount, type, category, description, status, occurred_at, user_id
 			), tx_wallet AS (
 				INSERT INTO affected_wallet
-				(transaction_id, wallet, role, user_id)
-				SELECT id, :wallet, :role, :user_id FROM tx
-				RETURNING wallet, role
+				(transaction_id, wallet, role, amount, user_id)
+				SELECT id, :wallet, :role, :wallet_amount, :user_id FROM tx
+				RETURNING wallet, role, amount AS wallet_amount
 			)
 			SELECT
-			tx.id AS id, amount, type, category,
-			description, occurred_at, wallet, role
+			tx.id AS id, amount, type, category, description,
+			status, occurred_at, wallet, role, wallet_amount, user_id
 			FROM tx, tx_wallet;
 		`
-		txMapper.transferStmt = `
+		txStmts.transferStmt = `
 			WITH tx AS (
 				INSERT INTO transaction
 				(amount, type, category, description, occurred_at, user_id)
 				VALUES
 				(:amount, :type, :category, :description, :occurred_at, :user_id)
-				RETURNING id, amount, type, category, description, occurred_at, user_id
+				RETURNING
+				id, amount, type, category, description, status, occurred_at, user_id
 			), tx_wallet AS (
 				INSERT INTO affected_wallet
-				(transaction_id, wallet, role, user_id)
+				(transaction_id, wallet, role, amount, user_id)
 				SELECT
-				id, :src_wallet, CAST ('SRC_WALLET' AS wallet_role), user_id FROM tx
+				id, :src_wallet, CAST ('SRC_WALLET' AS wallet_role),
+				amount, user_id
+				FROM tx
 				UNION ALL
 				SELECT
-				id, :dst_wallet, CAST ('DST_WALLET' AS wallet_role), user_id FROM tx
-				RETURNING wallet, role
+				id, :dst_wallet, CAST ('DST_WALLET' AS wallet_role),
+				COALESCE(CAST(:dst_amount AS numeric), amount), user_id
+				FROM tx
+				RETURNING wallet, role, amount AS wallet_amount
 			)
 			SELECT
 			id, w1.wallet AS src_wallet, w2.wallet AS dst_wallet,
-			amount, type, category, description, occurred_at, user_id
+			w2.wallet_amount AS dst_amount, amount, type, category, description,
+			status, occurred_at, user_id
 			FROM tx, tx_wallet w1, tx_wallet w2
 			WHERE w1.role = 'SRC_WALLET' AND w2.role = 'DST_WALLET';
 		`
-		txMapper.deleteStmt = `
+		txStmts.deleteStmt = `
+			WITH tx AS (
+				UPDATE transaction
+				SET deleted_at = CURRENT_TIMESTAMP
+				WHERE id = :id
+				AND user_id = :user_id
+				AND deleted_at IS NULL
+				RETURNING
+				id, amount, type, category, description, status, occurred_at,
+				deleted_at, user_id
+			)
+			SELECT
+			id, wallet, role, tx.amount,
+			COALESCE(w.amount, tx.amount) AS wallet_amount,
+			type, category, description, status, occurred_at, deleted_at,
+			tx.user_id
+			FROM tx, affected_wallet w
+			WHERE tx.id = w.transaction_id AND tx.user_id = w.user_id
+			ORDER BY role ASC;
+		`
+		txStmts.restoreStmt = `
+			WITH tx AS (
+				UPDATE transaction
+				SET deleted_at = NULL
+				WHERE id = :id
+				AND user_id = :user_id
+				AND deleted_at IS NOT NULL
+				RETURNING
+				id, amount, type, category, description, status, occurred_at,
+				user_id
+			)
+			SELECT
+			id, wallet, role, tx.amount,
+			COALESCE(w.amount, tx.amount) AS wallet_amount,
+			type, category, description, status, occurred_at, tx.user_id
+			FROM tx, affected_wallet w
+			WHERE tx.id = w.transaction_id AND tx.user_id = w.user_id
+			ORDER BY role ASC;
+		`
+		txStmts.purgeTrashStmt = `
+			WITH trashed AS (
+				SELECT id
+				FROM transaction
+				WHERE deleted_at < :before
+			), tx_wallet AS (
+				DELETE FROM affected_wallet
+				WHERE transaction_id IN (SELECT id FROM trashed)
+			)
+			DELETE FROM transaction
+			WHERE id IN (SELECT id FROM trashed)
+			RETURNING id, user_id;
+		`
+		txStmts.trashStmt = `
+			WITH page AS (
+				SELECT id
+				FROM transaction t
+				WHERE t.user_id = :user_id
+				AND t.deleted_at IS NOT NULL
+				AND (
+					CAST(:after_key AS uuid) IS NULL
+					OR (t.occurred_at, t.created_at, t.id) < (
+						CAST(:after_occurred_at AS timestamp with time zone),
+						CAST(:after_created_at AS timestamp with time zone),
+						CAST(:after_key AS uuid)
+					)
+				)
+				ORDER BY t.occurred_at DESC, t.created_at DESC, t.id DESC
+				LIMIT :limit
+			)
+			SELECT
+			t.id, wallet, role, t.amount, COALESCE(w.amount, t.amount) AS wallet_amount,
+			type, category, description, status, occurred_at, t.created_at,
+			t.deleted_at, t.user_id
+			FROM page p, transaction t, affected_wallet w
+			WHERE p.id = t.id AND t.id = w.transaction_id
+			AND t.user_id = w.user_id
+			ORDER BY occurred_at DESC, t.created_at DESC, t.id DESC, role ASC;
+		`
+		txStmts.purgeStmt = `
 			WITH tx AS (
 				DELETE FROM transaction
 				WHERE id = :id
 				AND user_id = :user_id
-				RETURNING id, amount, type, category, description, occurred_at, user_id
+				RETURNING
+				id, amount, type, category, description, status, occurred_at, user_id
 			), tx_wallet AS (
 				DELETE FROM affected_wallet
 				WHERE transaction_id = :id
 				AND user_id = :user_id
-				RETURNING transaction_id, wallet, role
+				RETURNING transaction_id, wallet, role, amount AS wallet_amount
 			)
 			SELECT
-			id, wallet, role, amount, type, category, description, occurred_at, user_id
+			id, wallet, role, amount, COALESCE(wallet_amount, amount) AS wallet_amount,
+			type, category, description, status, occurred_at, user_id
 			FROM tx, tx_wallet
 			WHERE tx.id = tx_wallet.transaction_id
 			ORDER BY role ASC;
 		`
-		txMapper.oneStmt = `
+		txStmts.oneStmt = `
 			SELECT
-			id, wallet, role, amount, type, category, description, occurred_at, t.user_id
+			id, wallet, role, t.amount, COALESCE(w.amount, t.amount) AS wallet_amount,
+			type, category, description, status, occurred_at, t.user_id
 			FROM transaction t, affected_wallet w
 			WHERE t.id = :id AND t.id = w.transaction_id
 			AND t.user_id = :user_id AND t.user_id = w.user_id
+			AND t.deleted_at IS NULL
 			ORDER BY role ASC;
 		`
-		txMapper.manyStmt = `
+		txStmts.lockStmt = `
 			SELECT
-			id, wallet, role, amount, type, category, description, occurred_at, t.user_id
+			id, wallet, role, t.amount, COALESCE(w.amount, t.amount) AS wallet_amount,
+			type, category, description, status, occurred_at, t.user_id
 			FROM transaction t, affected_wallet w
-			WHERE t.id IN (
-				SELECT transaction_id 
-				FROM affected_wallet
-				WHERE wallet = :wallet
-				AND user_id = :user_id
-			) AND t.id = w.transaction_id
-			AND t.user_id = w.user_id
+			WHERE t.id = :id AND t.id = w.transaction_id
+			AND t.user_id = :user_id AND t.user_id = w.user_id
+			AND t.deleted_at IS NULL
+			ORDER BY role ASC
+			FOR UPDATE OF t;
+		`
+		txStmts.unlinkStmt = `
+			DELETE FROM affected_wallet
+			WHERE transaction_id = :id
+			AND user_id = :user_id
+			RETURNING transaction_id AS id;
+		`
+		txStmts.statusStmt = `
+			UPDATE transaction t
+			SET status = :status
+			WHERE t.id = ANY (CAST(:ids AS uuid[]))
 			AND t.user_id = :user_id
-			ORDER BY occurred_at ASC, w.created_at ASC, role ASC;
+			AND t.deleted_at IS NULL
+			AND t.status <> 'RECONCILED'
+			AND (
+				CAST(:wallet AS text) = ''
+				OR EXISTS (
+					SELECT 1
+					FROM affected_wallet w
+					WHERE w.transaction_id = t.id
+					AND w.wallet = :wallet
+					AND w.user_id = t.user_id
+				)
+			)
+			RETURNING t.id;
+		`
+		txStmts.unlockStmt = `
+			UPDATE transaction
+			SET status = 'CLEARED'
+			WHERE id = :id
+			AND user_id = :user_id
+			AND deleted_at IS NULL
+			AND status = 'RECONCILED'
+			RETURNING id;
+		`
+		txStmts.updateStmt = `
+			WITH tx AS (
+				UPDATE transaction
+				SET amount = :amount, type = :type, category = :category,
+				description = :description, status = :status,
+				occurred_at = :occurred_at
+				WHERE id = :id
+				AND user_id = :user_id
+				AND deleted_at IS NULL
+				RETURNING
+				id, amount, type, category, description,
+				status, occurred_at, created_at, user_id
+			), tx_wallet AS (
+				INSERT INTO affected_wallet
+				(transaction_id, wallet, role, amount, created_at, user_id)
+				SELECT
+				id, :src_wallet, CAST ('SRC_WALLET' AS wallet_role),
+				amount, created_at, user_id
+				FROM tx WHERE :src_wallet <> ''
+				UNION ALL
+				SELECT
+				id, :dst_wallet, CAST ('DST_WALLET' AS wallet_role),
+				COALESCE(CAST(:dst_amount AS numeric), amount), created_at, user_id
+				FROM tx WHERE :dst_wallet <> ''
+				RETURNING wallet, role, amount AS wallet_amount
+			)
+			SELECT
+			id,
+			COALESCE(
+				(SELECT wallet FROM tx_wallet WHERE role = 'SRC_WALLET'), ''
+			) AS src_wallet,
+			COALESCE(
+				(SELECT wallet FROM tx_wallet WHERE role = 'DST_WALLET'), ''
+			) AS dst_wallet,
+			CASE WHEN type = 'TRANSFER' THEN
+				(SELECT wallet_amount FROM tx_wallet WHERE role = 'DST_WALLET')
+			END AS dst_amount,
+			amount, type, category, description, status, occurred_at, user_id
+			FROM tx;
 		`
-		txMapper.clearStmt = `
+		txStmts.manyStmt = fmt.Sprintf(`
+			WITH page AS (
+				SELECT id
+				FROM transaction t
+				WHERE t.id IN (
+					SELECT transaction_id
+					FROM affected_wallet
+					WHERE wallet = :wallet
+					AND user_id = :user_id
+				)
+				AND t.user_id = :user_id
+				AND t.deleted_at IS NULL
+				%s
+				AND (
+					CAST(:after_key AS uuid) IS NULL
+					OR (t.occurred_at, t.created_at, t.id) > (
+						CAST(:after_occurred_at AS timestamp with time zone),
+						CAST(:after_created_at AS timestamp with time zone),
+						CAST(:after_key AS uuid)
+					)
+				)
+				ORDER BY t.occurred_at ASC, t.created_at ASC, t.id ASC
+				LIMIT :limit
+			)
+			SELECT
+			t.id, wallet, role, t.amount, COALESCE(w.amount, t.amount) AS wallet_amount,
+			type, category, description, status, occurred_at, t.created_at, t.user_id
+			FROM page p, transaction t, affected_wallet w
+			WHERE p.id = t.id AND t.id = w.transaction_id
+			AND t.user_id = w.user_id
+			ORDER BY occurred_at ASC, t.created_at ASC, t.id ASC, role ASC;
+		`, txFilterCond)
+		txStmts.allStmt = fmt.Sprintf(`
+			WITH page AS (
+				SELECT id
+				FROM transaction t
+				WHERE t.user_id = :user_id
+				AND t.deleted_at IS NULL
+				%s
+				AND (
+					CAST(:after_key AS uuid) IS NULL
+					OR (t.occurred_at, t.created_at, t.id) < (
+						CAST(:after_occurred_at AS timestamp with time zone),
+						CAST(:after_created_at AS timestamp with time zone),
+						CAST(:after_key AS uuid)
+					)
+				)
+				ORDER BY t.occurred_at DESC, t.created_at DESC, t.id DESC
+				LIMIT :limit
+			)
+			SELECT
+			t.id, wallet, role, t.amount, COALESCE(w.amount, t.amount) AS wallet_amount,
+			type, category, description, status, occurred_at, t.created_at, t.user_id
+			FROM page p, transaction t, affected_wallet w
+			WHERE p.id = t.id AND t.id = w.transaction_id
+			AND t.user_id = w.user_id
+			ORDER BY occurred_at DESC, t.created_at DESC, t.id DESC, role ASC;
+		`, txFilterCond)
+		txStmts.clearStmt = `
 			WITH tx AS (
 				DELETE FROM transaction
 				WHERE user_id = :user_id
-				RETURNING id, amount, type, category, description, occurred_at, user_id
+				RETURNING
+				id, amount, type, category, description, status, occurred_at, user_id
 			), tx_wallet AS (
 				DELETE FROM affected_wallet
 				WHERE user_id = :user_id
 				RETURNING transaction_id, wallet, role, created_at
 			)
 			SELECT
-			id, wallet, role, amount, type, category, description, occurred_at, user_id
+			id, wallet, role, t.amount, COALESCE(w.amount, t.amount) AS wallet_amount,
+			type, category, description, status, occurred_at, t.user_id
 			FROM transaction t, affected_wallet w
 			WHERE t.id = w.transaction_id
 			ORDER BY occurred_at ASC, w.created_at ASC, role ASC;
 		`
 	})
 
-	txMapper.modelType = reflect.TypeOf(model)
-	txMapper.txType = txType
+	return &TxMapper{
+		BaseMapper: BaseMapper{
+			statements: &txStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		transactionStatements: &txStmts.transactionStatements,
+		txType:                txType,
+	}
+}
+
+func NewRecurringMapper(uow *UnitOfWork, model interface{}) *RecurringMapper {
+	recurringStmts.once.Do(func() {
+		recurringStmts.insertStmt = fmt.Sprintf(`
+			INSERT INTO recurring_rule
+			(type, amount, dst_amount, rate, src_wallet, dst_wallet, category,
+			description, frequency, repeat_every, day_of_month, start_date,
+			end_date, max_occurrences, next_date, user_id)
+			VALUES
+			(:type, :amount, :dst_amount, :rate, :src_wallet, :dst_wallet,
+			:category, :description, :frequency, :repeat_every, :day_of_month,
+			:start_date, :end_date, :max_occurrences, :next_date, :user_id)
+			RETURNING %s;
+		`, recurringColumns)
+		recurringStmts.deleteStmt = fmt.Sprintf(`
+			DELETE FROM recurring_rule
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, recurringColumns)
+		recurringStmts.oneStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM recurring_rule
+			WHERE id=:id
+			AND user_id=:user_id;
+		`, recurringColumns)
+		recurringStmts.updateStmt = fmt.Sprintf(`
+			UPDATE recurring_rule
+			SET type=:type, amount=:amount, dst_amount=:dst_amount,
+			rate=:rate, src_wallet=:src_wallet, dst_wallet=:dst_wallet,
+			category=:category,
+			description=:description, frequency=:frequency,
+			repeat_every=:repeat_every, day_of_month=:day_of_month,
+			start_date=:start_date, end_date=:end_date,
+			max_occurrences=:max_occurrences, occurrences=:occurrences,
+			next_date=:next_date, last_date=:last_date, last_error=NULL,
+			failed_at=NULL
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, recurringColumns)
+		recurringStmts.manyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM recurring_rule
+			WHERE user_id=:user_id
+			AND (
+				CAST(:after_key AS uuid) IS NULL
+				OR (created_at, id) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS uuid)
+				)
+			)
+			ORDER BY created_at ASC, id ASC
+			LIMIT :limit;
+		`, recurringColumns)
+		recurringStmts.dueStmt = `
+			SELECT id, user_id
+			FROM recurring_rule
+			WHERE next_date <= :today
+			ORDER BY next_date ASC;
+		`
+		recurringStmts.lockStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM recurring_rule
+			WHERE id=:id
+			AND user_id=:user_id
+			AND next_date <= :today
+			FOR UPDATE SKIP LOCKED;
+		`, recurringColumns)
+		recurringStmts.failStmt = fmt.Sprintf(`
+			UPDATE recurring_rule
+			SET last_error=:last_error, failed_at=CURRENT_TIMESTAMP
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, recurringColumns)
+		recurringStmts.postedStmt = `
+			SELECT rule_id, occurred_on, transaction_id, user_id
+			FROM recurring_posting
+			WHERE rule_id=:rule_id
+			AND occurred_on=:occurred_on;
+		`
+		recurringStmts.postStmt = `
+			INSERT INTO recurring_posting
+			(rule_id, occurred_on, transaction_id, user_id)
+			VALUES
+			(:rule_id, :occurred_on, :transaction_id, :user_id)
+			RETURNING rule_id, occurred_on, transaction_id, user_id;
+		`
+	})
+
+	return &RecurringMapper{
+		BaseMapper: BaseMapper{
+			statements: &recurringStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		recurringStatements: &recurringStmts.recurringStatements,
+	}
+}
+
+func NewExchangeRateMapper(
+	uow *UnitOfWork,
+	model interface{},
+) *ExchangeRateMapper {
+	exchangeRateStmts.once.Do(func() {
+		exchangeRateStmts.insertStmt = fmt.Sprintf(`
+			INSERT INTO exchange_rate
+			(src_currency, dst_currency, effective_on, rate, user_id)
+			VALUES
+			(:src_currency, :dst_currency, :effective_on, :rate, :user_id)
+			ON CONFLICT (user_id, src_currency, dst_currency, effective_on)
+			DO UPDATE SET rate = EXCLUDED.rate
+			RETURNING %s;
+		`, exchangeRateColumns)
+		exchangeRateStmts.deleteStmt = fmt.Sprintf(`
+			DELETE FROM exchange_rate
+			WHERE src_currency=:src_currency
+			AND dst_currency=:dst_currency
+			AND effective_on=:effective_on
+			AND user_id=:user_id
+			RETURNING %s;
+		`, exchangeRateColumns)
+		exchangeRateStmts.oneStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM exchange_rate
+			WHERE src_currency=:src_currency
+			AND dst_currency=:dst_currency
+			AND effective_on=:effective_on
+			AND user_id=:user_id;
+		`, exchangeRateColumns)
+		exchangeRateStmts.manyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM exchange_rate
+			WHERE user_id=:user_id
+			AND (
+				CAST(:src_currency AS text) = ''
+				OR src_currency=:src_currency
+			) AND (
+				CAST(:dst_currency AS text) = ''
+				OR dst_currency=:dst_currency
+			) AND (
+				CAST(:after_key AS text) IS NULL
+				OR %s > CAST(:after_key AS text)
+			)
+			ORDER BY %s ASC
+			LIMIT :limit;
+		`, exchangeRateColumns, exchangeRateKey, exchangeRateKey)
+		exchangeRateStmts.clearStmt = fmt.Sprintf(`
+			DELETE FROM exchange_rate
+			WHERE user_id=:user_id
+			RETURNING %s;
+		`, exchangeRateColumns)
+		exchangeRateStmts.historyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM exchange_rate
+			WHERE user_id=:user_id
+			AND (
+				(src_currency=:src_currency AND dst_currency=:dst_currency)
+				OR (src_currency=:dst_currency AND dst_currency=:src_currency)
+			)
+			ORDER BY effective_on ASC;
+		`, exchangeRateColumns)
+	})
+
+	return &ExchangeRateMapper{
+		BaseMapper: BaseMapper{
+			statements: &exchangeRateStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		exchangeRateStatements: &exchangeRateStmts.exchangeRateStatements,
+	}
+}
+
+func NewTagMapper(uow *UnitOfWork, model interface{}) *TagMapper {
+	tagStmts.once.Do(func() {
+		tagStmts.insertStmt = `
+			INSERT INTO tag (name, user_id)
+			VALUES (:name, :user_id)
+			RETURNING name, created_at, user_id;
+		`
+		tagStmts.deleteStmt = `
+			DELETE FROM tag
+			WHERE name=:name
+			AND user_id=:user_id
+			RETURNING name, created_at, user_id;
+		`
+		tagStmts.oneStmt = `
+			SELECT name, created_at, user_id
+			FROM tag
+			WHERE name=:name
+			AND user_id=:user_id;
+		`
+		tagStmts.updateStmt = `
+			UPDATE tag
+			SET name=:new_name
+			WHERE name=:name
+			AND user_id=:user_id
+			RETURNING name, created_at, user_id;
+		`
+		tagStmts.manyStmt = `
+			SELECT name, created_at, user_id
+			FROM tag
+			WHERE user_id=:user_id
+			AND (
+				CAST(:after_key AS text) IS NULL
+				OR (created_at, name) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS text)
+				)
+			)
+			ORDER BY created_at ASC, name ASC
+			LIMIT :limit;
+		`
+		tagStmts.ensureStmt = `
+			INSERT INTO tag (name, user_id)
+			SELECT DISTINCT name, CAST(:user_id AS text)
+			FROM unnest(CAST(:tags AS text[])) AS name
+			ON CONFLICT (user_id, name) DO NOTHING
+			RETURNING name, created_at, user_id;
+		`
+		tagStmts.attachStmt = `
+			INSERT INTO transaction_tag (transaction_id, tag, user_id)
+			SELECT DISTINCT
+			CAST(:transaction_id AS uuid), name, CAST(:user_id AS text)
+			FROM unnest(CAST(:tags AS text[])) AS name
+			ON CONFLICT (transaction_id, tag) DO NOTHING
+			RETURNING transaction_id, tag, user_id;
+		`
+		tagStmts.detachStmt = `
+			DELETE FROM transaction_tag
+			WHERE transaction_id = :transaction_id
+			AND tag = ANY (CAST(:tags AS text[]))
+			AND user_id = :user_id
+			RETURNING transaction_id, tag, user_id;
+		`
+		tagStmts.ofStmt = `
+			SELECT transaction_id, tag, user_id
+			FROM transaction_tag
+			WHERE transaction_id = ANY (CAST(:transaction_ids AS uuid[]))
+			AND user_id = :user_id
+			ORDER BY transaction_id ASC, tag ASC;
+		`
+		tagStmts.totalsStmt = `
+			SELECT
+			g.tag, w.currency, COUNT(*) AS count,
+			COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'EXPENSE'), 0)
+			AS expense,
+			COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'INCOME'), 0)
+			AS income,
+			COALESCE(SUM(t.amount) FILTER (WHERE t.type = 'TRANSFER'), 0)
+			AS transfer
+			FROM transaction_tag g, transaction t, affected_wallet a, wallet w
+			WHERE g.user_id = :user_id
+			AND t.id = g.transaction_id AND t.user_id = g.user_id
+			AND t.deleted_at IS NULL
+			AND a.transaction_id = t.id AND a.user_id = t.user_id
+			AND a.role = CASE
+				WHEN t.type = 'INCOME' THEN CAST('DST_WALLET' AS wallet_role)
+				ELSE CAST('SRC_WALLET' AS wallet_role)
+			END
+			AND w.name = a.wallet AND w.user_id = a.user_id
+			AND (
+				CAST(:from_date AS timestamp with time zone) IS NULL
+				OR t.occurred_at >= :from_date
+			) AND (
+				CAST(:to_date AS timestamp with time zone) IS NULL
+				OR t.occurred_at < :to_date
+			) AND (
+				CAST(:tags AS text[]) IS NULL
+				OR g.tag = ANY (CAST(:tags AS text[]))
+			)
+			GROUP BY g.tag, w.currency
+			ORDER BY g.tag ASC, w.currency ASC;
+		`
+	})
+
+	return &TagMapper{
+		BaseMapper: BaseMapper{
+			statements: &tagStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		tagStatements: &tagStmts.tagStatements,
+	}
+}
+
+func NewAttachmentMapper(
+	uow *UnitOfWork,
+	model interface{},
+) *AttachmentMapper {
+	attachmentStmts.once.Do(func() {
+		attachmentStmts.insertStmt = fmt.Sprintf(`
+			INSERT INTO attachment
+			(transaction_id, file_name, content_type, size, user_id)
+			VALUES
+			(:transaction_id, :file_name, :content_type, :size, :user_id)
+			RETURNING %s;
+		`, attachmentColumns)
+		attachmentStmts.deleteStmt = fmt.Sprintf(`
+			DELETE FROM attachment
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, attachmentColumns)
+		attachmentStmts.oneStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM attachment
+			WHERE id=:id
+			AND user_id=:user_id;
+		`, attachmentColumns)
+		attachmentStmts.manyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM attachment
+			WHERE transaction_id=:transaction_id
+			AND user_id=:user_id
+			ORDER BY created_at ASC, id ASC;
+		`, attachmentColumns)
+		attachmentStmts.trashedStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM attachment
+			WHERE transaction_id IN (
+				SELECT id
+				FROM transaction
+				WHERE deleted_at < :before
+			);
+		`, attachmentColumns)
+		attachmentStmts.ofUserStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM attachment
+			WHERE user_id=:user_id
+			ORDER BY created_at ASC, id ASC;
+		`, attachmentColumns)
+	})
+
+	return &AttachmentMapper{
+		BaseMapper: BaseMapper{
+			statements: &attachmentStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		attachmentStatements: &attachmentStmts.attachmentStatements,
+	}
+}
+
+func NewReconciliationMapper(
+	uow *UnitOfWork,
+	model interface{},
+) *ReconciliationMapper {
+	reconciliationStmts.once.Do(func() {
+		reconciliationStmts.insertStmt = fmt.Sprintf(`
+			INSERT INTO reconciliation
+			(wallet, statement_date, statement_balance, user_id)
+			VALUES
+			(:wallet, :statement_date, :statement_balance, :user_id)
+			RETURNING %s;
+		`, reconciliationColumns)
+		reconciliationStmts.deleteStmt = fmt.Sprintf(`
+			DELETE FROM reconciliation
+			WHERE id=:id
+			AND user_id=:user_id
+			AND finished_at IS NULL
+			RETURNING %s;
+		`, reconciliationColumns)
+		reconciliationStmts.oneStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM reconciliation
+			WHERE id=:id
+			AND user_id=:user_id;
+		`, reconciliationColumns)
+		reconciliationStmts.manyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM reconciliation
+			WHERE user_id=:user_id
+			AND (CAST(:wallet AS text) = '' OR wallet=:wallet)
+			AND (
+				CAST(:after_key AS uuid) IS NULL
+				OR (created_at, id) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS uuid)
+				)
+			)
+			ORDER BY created_at ASC, id ASC
+			LIMIT :limit;
+		`, reconciliationColumns)
+		reconciliationStmts.lockStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM reconciliation
+			WHERE id=:id
+			AND user_id=:user_id
+			AND finished_at IS NULL
+			FOR UPDATE;
+		`, reconciliationColumns)
+		reconciliationStmts.summaryStmt = fmt.Sprintf(`
+			SELECT
+			r.id, r.wallet, r.statement_date, r.statement_balance,
+			r.reconciled_count, r.finished_at, r.created_at, r.user_id,
+			w.balance - COALESCE(SUM(e.effect), 0) + COALESCE(
+				SUM(e.effect) FILTER (
+					WHERE e.status <> 'PENDING' AND e.occurred_at < %s
+				),
+				0
+			) AS cleared_balance
+			FROM reconciliation r
+			JOIN wallet w
+			ON w.name = r.wallet AND w.user_id = r.user_id
+			LEFT JOIN (%s) e
+			ON e.wallet = r.wallet AND e.user_id = r.user_id
+			WHERE r.id=:id
+			AND r.user_id=:user_id
+			GROUP BY r.id, w.balance;
+		`, reconciliationCutoff, walletEffects)
+		reconciliationStmts.finishStmt = fmt.Sprintf(`
+			WITH tx AS (
+				UPDATE transaction t
+				SET status = 'RECONCILED'
+				FROM reconciliation r
+				WHERE r.id = :id
+				AND r.user_id = :user_id
+				AND t.user_id = r.user_id
+				AND t.deleted_at IS NULL
+				AND t.status = 'CLEARED'
+				AND t.occurred_at < %s
+				AND EXISTS (
+					SELECT 1
+					FROM affected_wallet a
+					WHERE a.transaction_id = t.id
+					AND a.wallet = r.wallet
+					AND a.user_id = t.user_id
+				)
+				RETURNING t.id
+			)
+			UPDATE reconciliation
+			SET finished_at = CURRENT_TIMESTAMP,
+			reconciled_count = (SELECT COUNT(*) FROM tx)
+			WHERE id=:id
+			AND user_id=:user_id
+			AND finished_at IS NULL
+			RETURNING %s;
+		`, reconciliationCutoff, reconciliationColumns)
+	})
+
+	return &ReconciliationMapper{
+		BaseMapper: BaseMapper{
+			statements: &reconciliationStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		reconciliationStatements: &reconciliationStmts.reconciliationStatements,
+	}
+}
+
+func NewLedgerMapper(uow *UnitOfWork, model interface{}) *LedgerMapper {
+	ledgerStmts.once.Do(func() {
+		ledgerStmts.insertStmt = fmt.Sprintf(`
+			INSERT INTO ledger_repair
+			(kind, wallet, transaction_id, old_balance, new_balance, detail,
+			user_id)
+			VALUES
+			(:kind, :wallet, :transaction_id, :old_balance, :new_balance,
+			:detail, :user_id)
+			RETURNING %s;
+		`, ledgerRepairColumns)
+		ledgerStmts.manyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM ledger_repair
+			WHERE user_id=:user_id
+			AND (
+				CAST(:after_key AS text) IS NULL
+				OR (created_at, CAST(id AS text)) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS text)
+				)
+			)
+			ORDER BY created_at ASC, CAST(id AS text) ASC
+			LIMIT :limit;
+		`, ledgerRepairColumns)
+		ledgerStmts.balancesStmt = fmt.Sprintf(`
+			SELECT
+			w.name AS wallet, w.balance,
+			COALESCE(w.opening_balance, 0) + COALESCE(SUM(e.effect), 0)
+			AS computed_balance,
+			w.user_id
+			FROM wallet w
+			LEFT JOIN (%s) e
+			ON e.wallet = w.name AND e.user_id = w.user_id
+			WHERE (CAST(:user_id AS text) = '' OR w.user_id = :user_id)
+			GROUP BY w.name, w.user_id
+			ORDER BY w.user_id ASC, w.name ASC;
+		`, walletEffects)
+		ledgerStmts.brokenStmt = `
+			SELECT
+			t.id AS transaction_id, t.type, t.amount,
+			COALESCE(MIN(a.wallet), '') AS wallet,
+			COUNT(a.role) FILTER (WHERE a.role = 'SRC_WALLET') AS src_count,
+			COUNT(a.role) FILTER (WHERE a.role = 'DST_WALLET') AS dst_count,
+			t.user_id
+			FROM transaction t
+			LEFT JOIN affected_wallet a
+			ON a.transaction_id = t.id AND a.user_id = t.user_id
+			WHERE (CAST(:user_id AS text) = '' OR t.user_id = :user_id)
+			GROUP BY t.id
+			HAVING COUNT(a.role) = 0
+			OR COUNT(a.role) FILTER (WHERE a.role = 'SRC_WALLET') <> (
+				CASE WHEN t.type = 'INCOME' THEN 0 ELSE 1 END
+			)
+			OR COUNT(a.role) FILTER (WHERE a.role = 'DST_WALLET') <> (
+				CASE WHEN t.type = 'EXPENSE' THEN 0 ELSE 1 END
+			)
+			ORDER BY t.user_id ASC, t.id ASC;
+		`
+		ledgerStmts.lockWalletStmt = `
+			SELECT
+			name AS wallet, balance, balance AS computed_balance, user_id
+			FROM wallet
+			WHERE name=:wallet
+			AND user_id=:user_id
+			FOR UPDATE;
+		`
+		ledgerStmts.rebalanceStmt = fmt.Sprintf(`
+			UPDATE wallet w
+			SET balance = COALESCE(w.opening_balance, 0) + COALESCE((
+				SELECT SUM(e.effect)
+				FROM (%s) e
+				WHERE e.wallet = w.name AND e.user_id = w.user_id
+			), 0)
+			WHERE w.name=:wallet
+			AND w.user_id=:user_id
+			RETURNING
+			w.name AS wallet, w.balance, w.balance AS computed_balance,
+			w.user_id;
+		`, walletEffects)
+	})
+
+	return &LedgerMapper{
+		BaseMapper: BaseMapper{
+			statements: &ledgerStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		ledgerStatements: &ledgerStmts.ledgerStatements,
+	}
+}
+
+func NewBudgetMapper(uow *UnitOfWork, model interface{}) *BudgetMapper {
+	budgetStmts.once.Do(func() {
+		budgetStmts.insertStmt = fmt.Sprintf(`
+			INSERT INTO budget
+			(name, categories, period, amount, currency, user_id)
+			VALUES
+			(:name, :categories, :period, :amount, :currency, :user_id)
+			RETURNING %s;
+		`, budgetColumns)
+		budgetStmts.deleteStmt = fmt.Sprintf(`
+			DELETE FROM budget
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, budgetColumns)
+		budgetStmts.oneStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM budget
+			WHERE id=:id
+			AND user_id=:user_id;
+		`, budgetColumns)
+		budgetStmts.updateStmt = fmt.Sprintf(`
+			UPDATE budget
+			SET name=:name, categories=:categories, period=:period,
+			amount=:amount, currency=:currency
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, budgetColumns)
+		budgetStmts.manyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM budget
+			WHERE user_id=:user_id
+			AND (
+				CAST(:after_key AS uuid) IS NULL
+				OR (created_at, id) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS uuid)
+				)
+			)
+			ORDER BY created_at ASC, id ASC
+			LIMIT :limit;
+		`, budgetColumns)
+		budgetStmts.allStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM budget
+			WHERE user_id=:user_id
+			ORDER BY created_at ASC, id ASC;
+		`, budgetColumns)
+		// a category counts toward the nearest of :categories above it, so a
+		// transaction is counted once even when one of them is below another
+		budgetStmts.spentStmt = `
+			WITH RECURSIVE tree AS (
+				SELECT name AS category, name AS member, user_id, 1 AS depth
+				FROM category
+				WHERE user_id = :user_id
+				AND name = ANY (CAST(:categories AS text[]))
+				UNION ALL
+				SELECT tree.category, c.name, c.user_id, tree.depth + 1
+				FROM category c, tree
+				WHERE c.parent = tree.member AND c.user_id = tree.user_id
+				AND tree.depth <= :max_depth
+			), nearest AS (
+				SELECT DISTINCT ON (member) category, member
+				FROM tree
+				ORDER BY member, depth ASC
+			), lines AS (
+				SELECT
+				COALESCE(s.category, t.category) AS category,
+				COALESCE(s.amount, t.amount) AS amount
+				FROM transaction t
+				JOIN affected_wallet a
+				ON a.transaction_id = t.id AND a.user_id = t.user_id
+				AND a.role = 'SRC_WALLET'
+				JOIN wallet w ON w.name = a.wallet AND w.user_id = a.user_id
+				LEFT JOIN transaction_split s
+				ON s.transaction_id = t.id AND s.user_id = t.user_id
+				WHERE t.user_id = :user_id
+				AND t.deleted_at IS NULL
+				AND t.type = 'EXPENSE'
+				AND w.currency = :currency
+				AND t.occurred_at >= :from_date
+				AND t.occurred_at < :to_date
+			)
+			SELECT n.category, COALESCE(SUM(l.amount), 0) AS spent
+			FROM nearest n
+			LEFT JOIN lines l ON l.category = n.member
+			GROUP BY n.category
+			ORDER BY n.category ASC;
+		`
+	})
+
+	return &BudgetMapper{
+		BaseMapper: BaseMapper{
+			statements: &budgetStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		budgetStatements: &budgetStmts.budgetStatements,
+	}
+}
+
+func NewEnvelopeMapper(uow *UnitOfWork, model interface{}) *EnvelopeMapper {
+	envelopeStmts.once.Do(func() {
+		envelopeStmts.insertStmt = fmt.Sprintf(`
+			INSERT INTO envelope
+			(name, categories, currency, start_month, user_id)
+			VALUES
+			(:name, :categories, :currency, :start_month, :user_id)
+			RETURNING %s;
+		`, envelopeColumns)
+		envelopeStmts.deleteStmt = fmt.Sprintf(`
+			DELETE FROM envelope
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, envelopeColumns)
+		envelopeStmts.oneStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM envelope
+			WHERE id=:id
+			AND user_id=:user_id;
+		`, envelopeColumns)
+		envelopeStmts.updateStmt = fmt.Sprintf(`
+			UPDATE envelope
+			SET name=:name, categories=:categories
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, envelopeColumns)
+		envelopeStmts.manyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM envelope
+			WHERE user_id=:user_id
+			AND (
+				CAST(:after_key AS uuid) IS NULL
+				OR (created_at, id) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS uuid)
+				)
+			)
+			ORDER BY created_at ASC, id ASC
+			LIMIT :limit;
+		`, envelopeColumns)
+		envelopeStmts.allStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM envelope
+			WHERE user_id=:user_id
+			ORDER BY created_at ASC, id ASC;
+		`, envelopeColumns)
+		envelopeStmts.overlapStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM envelope
+			WHERE user_id=:user_id
+			AND CAST(id AS text) <> :id
+			AND categories && CAST(:categories AS text[]);
+		`, envelopeColumns)
+		envelopeStmts.insertEntryStmt = fmt.Sprintf(`
+			INSERT INTO envelope_entry
+			(envelope_id, month, kind, amount, note, user_id)
+			VALUES
+			(:envelope_id, :month, :kind, :amount, :note, :user_id)
+			RETURNING %s;
+		`, envelopeEntryColumns)
+		// a category counts toward the envelope of the nearest category
+		// above it, so an expense draws down one envelope only
+		envelopeStmts.activityStmt = `
+			WITH RECURSIVE roots AS (
+				SELECT
+				e.id AS envelope_id, unnest(e.categories) AS category,
+				e.currency, e.start_month, e.user_id
+				FROM envelope e
+				WHERE e.user_id = :user_id
+			), tree AS (
+				SELECT
+				r.envelope_id, r.currency, r.start_month, c.name AS member,
+				c.user_id, 1 AS depth
+				FROM roots r, category c
+				WHERE c.name = r.category AND c.user_id = r.user_id
+				UNION ALL
+				SELECT
+				tree.envelope_id, tree.currency, tree.start_month, c.name,
+				c.user_id, tree.depth + 1
+				FROM category c, tree
+				WHERE c.parent = tree.member AND c.user_id = tree.user_id
+				AND tree.depth <= :max_depth
+			), nearest AS (
+				SELECT DISTINCT ON (member)
+				envelope_id, currency, start_month, member
+				FROM tree
+				ORDER BY member, depth ASC, envelope_id ASC
+			), spent AS (
+				SELECT
+				n.envelope_id,
+				CAST(date_trunc('month', t.occurred_at AT TIME ZONE 'UTC') AS date)
+				AS month,
+				SUM(COALESCE(s.amount, t.amount)) AS amount
+				FROM transaction t
+				JOIN affected_wallet a
+				ON a.transaction_id = t.id AND a.user_id = t.user_id
+				AND a.role = 'SRC_WALLET'
+				JOIN wallet w ON w.name = a.wallet AND w.user_id = a.user_id
+				LEFT JOIN transaction_split s
+				ON s.transaction_id = t.id AND s.user_id = t.user_id
+				JOIN nearest n
+				ON n.member = COALESCE(s.category, t.category)
+				AND n.currency = w.currency
+				WHERE t.user_id = :user_id
+				AND t.deleted_at IS NULL
+				AND t.type = 'EXPENSE'
+				AND t.occurred_at >=
+					CAST(n.start_month AS timestamp) AT TIME ZONE 'UTC'
+				AND t.occurred_at < :to_date
+				GROUP BY n.envelope_id, month
+			), entries AS (
+				SELECT
+				envelope_id, month,
+				SUM(amount) FILTER (WHERE kind = 'ASSIGN') AS assigned,
+				SUM(amount) FILTER (WHERE kind = 'MOVE') AS moved
+				FROM envelope_entry
+				WHERE user_id = :user_id
+				AND month < :to_date
+				GROUP BY envelope_id, month
+			)
+			SELECT
+			COALESCE(sp.envelope_id, en.envelope_id) AS envelope_id,
+			COALESCE(sp.month, en.month) AS month,
+			COALESCE(en.assigned, 0) AS assigned,
+			COALESCE(en.moved, 0) AS moved,
+			COALESCE(sp.amount, 0) AS spent
+			FROM spent sp
+			FULL JOIN entries en
+			ON en.envelope_id = sp.envelope_id AND en.month = sp.month
+			ORDER BY envelope_id ASC, month ASC;
+		`
+		envelopeStmts.incomeStmt = `
+			SELECT
+			w.currency,
+			CAST(date_trunc('month', t.occurred_at AT TIME ZONE 'UTC') AS date)
+			AS month,
+			SUM(COALESCE(a.amount, t.amount)) AS income
+			FROM transaction t, affected_wallet a, wallet w
+			WHERE t.user_id = :user_id
+			AND t.deleted_at IS NULL
+			AND t.type = 'INCOME'
+			AND a.transaction_id = t.id AND a.user_id = t.user_id
+			AND a.role = 'DST_WALLET'
+			AND w.name = a.wallet AND w.user_id = a.user_id
+			AND t.occurred_at >= :from_date
+			AND t.occurred_at < :to_date
+			GROUP BY w.currency, month
+			ORDER BY w.currency ASC, month ASC;
+		`
+	})
+
+	return &EnvelopeMapper{
+		BaseMapper: BaseMapper{
+			statements: &envelopeStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		envelopeStatements: &envelopeStmts.envelopeStatements,
+	}
+}
+
+func NewGoalMapper(uow *UnitOfWork, model interface{}) *GoalMapper {
+	goalStmts.once.Do(func() {
+		goalStmts.insertStmt = fmt.Sprintf(`
+			INSERT INTO goal
+			(name, target_amount, target_date, currency, user_id)
+			VALUES
+			(:name, :target_amount, :target_date, :currency, :user_id)
+			RETURNING %s;
+		`, goalColumns)
+		goalStmts.deleteStmt = fmt.Sprintf(`
+			DELETE FROM goal
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, goalColumns)
+		goalStmts.oneStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM goal
+			WHERE id=:id
+			AND user_id=:user_id;
+		`, goalColumns)
+		goalStmts.updateStmt = fmt.Sprintf(`
+			UPDATE goal
+			SET
+			name=:name, target_amount=:target_amount, target_date=:target_date,
+			currency=:currency
+			WHERE id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, goalColumns)
+		goalStmts.manyStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM goal
+			WHERE user_id=:user_id
+			AND (
+				CAST(:after_key AS uuid) IS NULL
+				OR (created_at, id) > (
+					CAST(:after_created_at AS timestamp with time zone),
+					CAST(:after_key AS uuid)
+				)
+			)
+			ORDER BY created_at ASC, id ASC
+			LIMIT :limit;
+		`, goalColumns)
+		goalStmts.allStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM goal
+			WHERE user_id=:user_id
+			ORDER BY created_at ASC, id ASC;
+		`, goalColumns)
+		goalStmts.linkStmt = fmt.Sprintf(`
+			INSERT INTO goal_wallet
+			(goal_id, wallet, share, user_id)
+			VALUES
+			(:goal_id, :wallet, :share, :user_id)
+			RETURNING %s;
+		`, goalWalletColumns)
+		goalStmts.unlinkStmt = fmt.Sprintf(`
+			DELETE FROM goal_wallet
+			WHERE goal_id=:id
+			AND user_id=:user_id
+			RETURNING %s;
+		`, goalWalletColumns)
+		goalStmts.linksStmt = fmt.Sprintf(`
+			SELECT %s
+			FROM goal_wallet
+			WHERE user_id=:user_id
+			AND goal_id = ANY (CAST(:ids AS uuid[]))
+			ORDER BY goal_id ASC, wallet ASC;
+		`, goalWalletColumns)
+		goalStmts.sharedStmt = `
+			SELECT wallet, SUM(share) AS share
+			FROM goal_wallet
+			WHERE user_id=:user_id
+			AND CAST(goal_id AS text) <> :id
+			AND wallet = ANY (CAST(:wallets AS text[]))
+			GROUP BY wallet
+			ORDER BY wallet ASC;
+		`
+		goalStmts.savedStmt = fmt.Sprintf(`
+			SELECT
+			g.wallet, g.share, w.balance,
+			COALESCE((
+				SELECT SUM(e.effect)
+				FROM (%s) e
+				WHERE e.wallet = w.name
+				AND e.user_id = w.user_id
+				AND e.occurred_at >= :from_date
+				AND e.occurred_at < :to_date
+			), 0) AS net_inflow
+			FROM goal_wallet g, wallet w
+			WHERE g.goal_id=:id
+			AND g.user_id=:user_id
+			AND w.name = g.wallet AND w.user_id = g.user_id
+			AND w.deleted_at IS NULL
+			ORDER BY g.wallet ASC;
+		`, walletEffects)
+	})
 
-	return &txMapper
+	return &GoalMapper{
+		BaseMapper: BaseMapper{
+			statements: &goalStmts.statements,
+			modelType:  reflect.TypeOf(model),
+			uow:        uow,
+		},
+		goalStatements: &goalStmts.goalStatements,
+	}
 }
@@ -0,0 +1,15 @@
+package orm
+
+import (
+	"time"
+)
+
+// Page the structure carries the keyset pagination parameters of Many, it is
+// embedded in the parameters passed to Many. Rows come after the row keyed by
+// the After fields, at most Limit of them, a nil Limit returns every row.
+type Page struct {
+	Limit           *int       `db:"limit"`
+	AfterOccurredAt *time.Time `db:"after_occurred_at"`
+	AfterCreatedAt  *time.Time `db:"after_created_at"`
+	AfterKey        *string    `db:"after_key"`
+}
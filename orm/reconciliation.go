@@ -0,0 +1,50 @@
+package orm
+
+// reconciliationStatements holds the statements ReconciliationMapper has on
+// top of those of BaseMapper
+type reconciliationStatements struct {
+	lockStmt    string
+	summaryStmt string
+	finishStmt  string
+}
+
+type ReconciliationMapper struct {
+	BaseMapper
+	*reconciliationStatements
+}
+
+// Lock holds an open reconciliation until the unit of work ends
+func (mapper *ReconciliationMapper) Lock(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.lockStmt,
+		"Error locking",
+	)
+}
+
+// Summary returns a reconciliation along with the balance its wallet has
+// when only the transactions which have hit the bank by the statement date
+// are counted
+func (mapper *ReconciliationMapper) Summary(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.summaryStmt,
+		"Error selecting",
+	)
+}
+
+// Finish closes an open reconciliation and marks the cleared transactions it
+// covers as reconciled
+func (mapper *ReconciliationMapper) Finish(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.finishStmt,
+		"Error updating",
+	)
+}
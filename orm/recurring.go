@@ -0,0 +1,73 @@
+package orm
+
+// recurringStatements holds the statements RecurringMapper has on top of
+// those of BaseMapper
+type recurringStatements struct {
+	dueStmt    string
+	lockStmt   string
+	postedStmt string
+	postStmt   string
+	failStmt   string
+}
+
+type RecurringMapper struct {
+	BaseMapper
+	*recurringStatements
+}
+
+// Due lists the recurring rules of every user which are due by :today
+func (mapper *RecurringMapper) Due(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.dueStmt,
+		"Error selecting",
+	)
+}
+
+// Lock holds a recurring rule which is due by :today until the unit of work
+// ends, a rule held by another unit of work is skipped as if it was not due
+func (mapper *RecurringMapper) Lock(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.lockStmt,
+		"Error locking",
+	)
+}
+
+// Posted returns the posting of a recurring rule on :occurred_on
+func (mapper *RecurringMapper) Posted(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.postedStmt,
+		"Error getting",
+	)
+}
+
+// Post records that a recurring rule was posted on :occurred_on
+func (mapper *RecurringMapper) Post(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.postStmt,
+		"Error inserting",
+	)
+}
+
+// Fail records :last_error as the reason a recurring rule could not be
+// posted, along with when it happened
+func (mapper *RecurringMapper) Fail(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.failStmt,
+		"Error updating",
+	)
+}
@@ -0,0 +1,16 @@
+package orm
+
+type SplitMapper struct {
+	BaseMapper
+}
+
+// Delete removes every split line of a transaction
+func (mapper *SplitMapper) Delete(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.deleteStmt,
+		"Error deleting",
+	)
+}
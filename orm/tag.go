@@ -0,0 +1,71 @@
+package orm
+
+// tagStatements holds the statements TagMapper has on top of those of
+// BaseMapper
+type tagStatements struct {
+	ensureStmt string
+	attachStmt string
+	detachStmt string
+	ofStmt     string
+	totalsStmt string
+}
+
+type TagMapper struct {
+	BaseMapper
+	*tagStatements
+}
+
+// Ensure inserts those of :tags the user does not have yet
+func (mapper *TagMapper) Ensure(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.ensureStmt,
+		"Error inserting",
+	)
+}
+
+// Attach tags a transaction with :tags, a tag it already has is skipped
+func (mapper *TagMapper) Attach(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.attachStmt,
+		"Error inserting",
+	)
+}
+
+// Detach takes :tags off a transaction
+func (mapper *TagMapper) Detach(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.detachStmt,
+		"Error deleting",
+	)
+}
+
+// Of lists the tags of the transactions in :transaction_ids
+func (mapper *TagMapper) Of(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.ofStmt,
+		"Error selecting",
+	)
+}
+
+// Totals sums up the transactions of each tag by currency and type
+func (mapper *TagMapper) Totals(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.totalsStmt,
+		"Error selecting",
+	)
+}
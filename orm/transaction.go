@@ -6,10 +6,22 @@ import (
 	"github.com/expenseledger/web-service/constant"
 )
 
+// transactionStatements holds the statements TxMapper has on top of those of
+// BaseMapper
+type transactionStatements struct {
+	transferStmt string
+	lockStmt     string
+	unlinkStmt   string
+	allStmt      string
+	statusStmt   string
+	unlockStmt   string
+	purgeStmt    string
+}
+
 type TxMapper struct {
 	BaseMapper
-	transferStmt string
-	txType       constant.TransactionType
+	*transactionStatements
+	txType constant.TransactionType
 }
 
 func (mapper *TxMapper) Insert(obj interface{}) (interface{}, error) {
@@ -17,6 +29,7 @@ func (mapper *TxMapper) Insert(obj interface{}) (interface{}, error) {
 	switch mapper.txType {
 	case txType.Transfer:
 		return worker(
+			mapper.uow,
 			obj,
 			mapper.modelType,
 			mapper.transferStmt,
@@ -26,6 +39,7 @@ func (mapper *TxMapper) Insert(obj interface{}) (interface{}, error) {
 		fallthrough
 	case txType.Income:
 		return worker(
+			mapper.uow,
 			obj,
 			mapper.modelType,
 			mapper.insertStmt,
@@ -37,6 +51,7 @@ func (mapper *TxMapper) Insert(obj interface{}) (interface{}, error) {
 
 func (mapper *TxMapper) One(obj interface{}) (interface{}, error) {
 	return sliceWorker(
+		mapper.uow,
 		obj,
 		mapper.modelType,
 		mapper.oneStmt,
@@ -44,11 +59,113 @@ func (mapper *TxMapper) One(obj interface{}) (interface{}, error) {
 	)
 }
 
+// Delete moves a transaction to the trash, its affected wallets stay along
+// with it
 func (mapper *TxMapper) Delete(obj interface{}) (interface{}, error) {
 	return sliceWorker(
+		mapper.uow,
 		obj,
 		mapper.modelType,
 		mapper.deleteStmt,
 		"Error deleting",
 	)
 }
+
+// Lock is One which also holds the transaction row until the unit of work
+// ends, so concurrent edits of one transaction cannot interleave
+func (mapper *TxMapper) Lock(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.lockStmt,
+		"Error locking",
+	)
+}
+
+// Update rewrites a transaction together with its affected wallets, it needs
+// a unit of work since the old affected wallets are removed by a statement
+// of their own
+func (mapper *TxMapper) Update(obj interface{}) (interface{}, error) {
+	if mapper.uow == nil {
+		return nil, errors.New("updating a transaction needs a unit of work")
+	}
+
+	_, err := sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.unlinkStmt,
+		"Error updating",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.updateStmt,
+		"Error updating",
+	)
+}
+
+// All is Many over every wallet of the user, newest first
+func (mapper *TxMapper) All(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.allStmt,
+		"Error selecting",
+	)
+}
+
+// SetStatus sets the status of the transactions in :ids which are not
+// reconciled and, if :wallet is not empty, affect it. It returns the IDs of
+// those it has set.
+func (mapper *TxMapper) SetStatus(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.statusStmt,
+		"Error updating",
+	)
+}
+
+// Unlock takes a reconciled transaction back to cleared, so it can be edited
+func (mapper *TxMapper) Unlock(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.unlockStmt,
+		"Error updating",
+	)
+}
+
+// Restore takes a transaction out of the trash along with its affected
+// wallets
+func (mapper *TxMapper) Restore(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.restoreStmt,
+		"Error restoring",
+	)
+}
+
+// Purge removes a transaction for good, whether it is in the trash or not,
+// along with its affected wallets
+func (mapper *TxMapper) Purge(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.purgeStmt,
+		"Error deleting",
+	)
+}
@@ -0,0 +1,68 @@
+package orm
+
+import (
+	"log"
+
+	"github.com/expenseledger/web-service/db"
+	"github.com/jmoiron/sqlx"
+)
+
+// UnitOfWork groups mapper calls into one DB transaction so that they
+// commit or roll back together
+type UnitOfWork struct {
+	tx *sqlx.Tx
+}
+
+// NewUnitOfWork begins a new DB transaction
+func NewUnitOfWork() (*UnitOfWork, error) {
+	tx, err := db.Conn().Beginx()
+	if err != nil {
+		log.Println("Error beginning transaction", err)
+		return nil, err
+	}
+	return &UnitOfWork{tx: tx}, nil
+}
+
+// Commit ...
+func (uow *UnitOfWork) Commit() error {
+	if err := uow.tx.Commit(); err != nil {
+		log.Println("Error committing transaction", err)
+		return err
+	}
+	return nil
+}
+
+// Rollback ...
+func (uow *UnitOfWork) Rollback() error {
+	if err := uow.tx.Rollback(); err != nil {
+		log.Println("Error rolling back transaction", err)
+		return err
+	}
+	return nil
+}
+
+// run executes fn on the DB transaction of the unit of work, a nil unit of
+// work runs fn on a DB transaction of its own
+func (uow *UnitOfWork) run(
+	fn func(tx *sqlx.Tx) (interface{}, error),
+) (interface{}, error) {
+	if uow != nil {
+		return fn(uow.tx)
+	}
+
+	own, err := NewUnitOfWork()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := fn(own.tx)
+	if err != nil {
+		own.Rollback()
+		return nil, err
+	}
+
+	if err := own.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package orm
+
+import "errors"
+
+// walletStatements holds the statements WalletMapper has on top of those of
+// BaseMapper
+type walletStatements struct {
+	shiftBalanceStmt    string
+	expendStmt          string
+	creditStmt          string
+	overdraftStmt       string
+	paymentsStmt        string
+	balancesAtStmt      string
+	runningBalancesStmt string
+	renameStmt          string
+	dropStmt            string
+	findStmt            string
+}
+
+type WalletMapper struct {
+	BaseMapper
+	*walletStatements
+}
+
+// ShiftBalance adds :amount, which may be negative, to the balance of the
+// wallet in SQL, so concurrent shifts on one wallet serialize on its row lock
+// instead of overwriting each other
+func (mapper *WalletMapper) ShiftBalance(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.shiftBalanceStmt,
+		"Error shifting balance",
+	)
+}
+
+// Expend is ShiftBalance for an amount taken out of the wallet, which it
+// leaves as it is when a credit wallet would go over its credit limit or the
+// wallet below what its overdraft policy allows, no rows are returned then
+func (mapper *WalletMapper) Expend(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.expendStmt,
+		"Error shifting balance",
+	)
+}
+
+// SetCredit sets the credit limit, the statement closing day and the payment
+// due day of wallet :name
+func (mapper *WalletMapper) SetCredit(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.creditStmt,
+		"Error updating",
+	)
+}
+
+// Find is One which also finds wallet :name when it is in the trash
+func (mapper *WalletMapper) Find(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.findStmt,
+		"Error selecting",
+	)
+}
+
+// SetOverdraft sets the overdraft policy and the overdraft floor of wallet
+// :name
+func (mapper *WalletMapper) SetOverdraft(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.overdraftStmt,
+		"Error updating",
+	)
+}
+
+// Payments sums up the transfers into wallet :name which occurred from
+// :from_date until :to_date
+func (mapper *WalletMapper) Payments(obj interface{}) (interface{}, error) {
+	return worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.paymentsStmt,
+		"Error selecting",
+	)
+}
+
+// BalancesAt returns the balance each wallet of :user_id, or only wallet
+// :name when it is not empty, had at the end of each of :dates
+func (mapper *WalletMapper) BalancesAt(obj interface{}) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.balancesAtStmt,
+		"Error selecting",
+	)
+}
+
+// RunningBalances returns the balance wallet :name had right after each of
+// the transactions :ids, in the order transactions are listed in
+func (mapper *WalletMapper) RunningBalances(
+	obj interface{},
+) (interface{}, error) {
+	return sliceWorker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.runningBalancesStmt,
+		"Error selecting",
+	)
+}
+
+// Rename moves wallet :name to :new_name along with the postings, the
+// reconciliations, the recurring rules and the goals which refer to it by
+// name. It needs a unit of work since the wallet under its old name is removed
+// by a statement of its own, once nothing refers to it anymore.
+func (mapper *WalletMapper) Rename(obj interface{}) (interface{}, error) {
+	if mapper.uow == nil {
+		return nil, errors.New("renaming a wallet needs a unit of work")
+	}
+
+	wallet, err := worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.renameStmt,
+		"Error updating",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = worker(
+		mapper.uow,
+		obj,
+		mapper.modelType,
+		mapper.dropStmt,
+		"Error updating",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
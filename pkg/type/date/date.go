@@ -1,7 +1,9 @@
 package date
 
 import (
+	"database/sql/driver"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -45,3 +47,19 @@ func (d Date) String() string {
 	t := time.Time(d)
 	return t.String()
 }
+
+// Scan implements the sql.Scanner interface for date columns
+func (d *Date) Scan(src interface{}) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("Date.Scan: cannot scan %T", src)
+	}
+
+	*d = Date(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+	return nil
+}
+
+// Value implements the driver.Valuer interface for date columns
+func (d Date) Value() (driver.Value, error) {
+	return time.Time(d), nil
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/expenseledger/web-service/model"
+)
+
+// RunRecurringScheduler posts the due occurrences of recurring transactions
+// right away and then once every interval, it never returns
+func RunRecurringScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		posted, err := model.PostDueRecurringRules(time.Now())
+		if err != nil {
+			log.Println("Error posting recurring transactions", err)
+		}
+		if posted > 0 {
+			log.Println("Posted recurring transactions:", posted)
+		}
+
+		<-ticker.C
+	}
+}
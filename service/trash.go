@@ -0,0 +1,33 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/expenseledger/web-service/model"
+)
+
+// RunTrashPurger removes for good what has been in the trash for longer than
+// retention, right away and then once every interval, it never returns
+func RunTrashPurger(interval time.Duration, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purge, err := model.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			log.Println("Error purging the trash", err)
+		}
+		if purge != nil &&
+			purge.Transactions+purge.Wallets+purge.Categories > 0 {
+			log.Printf(
+				"Purged the trash: %d transactions, %d wallets, %d categories",
+				purge.Transactions,
+				purge.Wallets,
+				purge.Categories,
+			)
+		}
+
+		<-ticker.C
+	}
+}
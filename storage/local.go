@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore the structure is a Store which keeps each blob in a file of its
+// own under Root
+type LocalStore struct {
+	Root string
+}
+
+// NewLocalStore returns a store keeping blobs under root, the directory is
+// created when the first blob is put
+func NewLocalStore(root string) *LocalStore {
+	return &LocalStore{Root: root}
+}
+
+// Put writes the blob to a temporary file first, so a reader never sees a
+// blob half written
+func (s *LocalStore) Put(key string, r io.Reader) (err error) {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(s.Root, 0700); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(s.Root, ".put-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(file.Name())
+		}
+	}()
+
+	if _, err = io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// Get ...
+func (s *LocalStore) Get(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Delete ...
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// path returns the file of the blob stored under key, a key may not reach
+// out of Root
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, ".") ||
+		strings.ContainsAny(key, `/\`) {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(s.Root, key), nil
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"errors"
+	"io"
+
+	"github.com/expenseledger/web-service/config"
+)
+
+// Store the interface keeps blobs, such as the files attached to
+// transactions, under keys given by the caller
+type Store interface {
+	// Put stores the content of r under key, replacing what was there
+	Put(key string, r io.Reader) error
+	// Get opens the content stored under key, the caller closes it
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key, a missing key is not an
+	// error
+	Delete(key string) error
+}
+
+// ErrNotFound is returned by Get when nothing is stored under the key
+var ErrNotFound = errors.New("blob not found")
+
+var store Store
+
+func init() {
+	store = NewLocalStore(config.GetConfigs().AttachmentDir)
+}
+
+// Default returns the store the service keeps its blobs in
+func Default() Store {
+	return store
+}
+
+// SetDefault replaces the store the service keeps its blobs in, it is meant
+// to be called before the service starts
+func SetDefault(s Store) {
+	store = s
+}